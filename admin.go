@@ -0,0 +1,361 @@
+// 日志管理接口
+// AdminHandler 返回可以挂载到已有 http 服务上的 handler ，提供日志级别、按名称设置的日志级别、
+// 按 trace id 开启 debug 日志、当前配置、日志输出状态和最近日志的查询接口
+
+package logging
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AdminOption 日志管理接口配置
+type AdminOption struct {
+	// 认证和 IP 白名单配置， Addr 和 TLS 配置只在 StartAtomicLevelServer 中使用
+	AtomicLevelServerOption
+	// 查询和修改的 atomic level ，为 nil 时使用默认 logger 的 atomic level
+	Level *zap.AtomicLevel
+	// 最近的日志，为 nil 时 /logs 接口返回 404
+	RecentLogs RecentLogsSource
+}
+
+// LogEntry 最近日志中的一条日志
+type LogEntry struct {
+	Time       time.Time              `json:"time"`
+	Level      string                 `json:"level"`
+	LoggerName string                 `json:"logger,omitempty"`
+	Message    string                 `json:"msg"`
+	Caller     string                 `json:"caller,omitempty"`
+	Stack      string                 `json:"stacktrace,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+}
+
+// RecentLogsSource 提供最近的日志
+type RecentLogsSource interface {
+	// RecentLogs 返回最近的 limit 条日志，按时间顺序排列， limit 小于等于 0 时返回全部
+	RecentLogs(limit int) []LogEntry
+}
+
+var (
+	// globalOptions 默认 logger 当前使用的配置
+	globalOptions Options
+)
+
+// setGlobalOptions 记录默认 logger 当前使用的配置
+func setGlobalOptions(options Options) {
+	rwMutex.Lock()
+	defer rwMutex.Unlock()
+	globalOptions = options
+}
+
+// AdminHandler 返回日志管理接口的 handler ，可以挂载到已有的 http 服务上
+//
+// GET/PUT / 查询和修改日志级别，支持 ttl
+// GET/PUT/DELETE /names 查询和修改按名称设置的日志级别
+// GET/PUT/DELETE /force_debug 查询和注册开启 debug 日志的 trace id
+// GET /options 默认 logger 当前使用的配置
+// GET /sinks 默认 logger 日志输出的状态
+// GET /logs?limit=100 最近的日志，使用 RingCore 时支持 level 、 logger 、 trace_id 、 since 、 until 过滤和 stream=true 实时推送
+//
+// mux.Handle("/logging/", http.StripPrefix("/logging", handler))
+func AdminHandler(option AdminOption) (http.Handler, error) {
+	if err := option.validateAuth(); err != nil {
+		return nil, err
+	}
+	allowNets, err := parseAllowIPs(option.AllowIPs)
+	if err != nil {
+		return nil, err
+	}
+	level := func() zap.AtomicLevel {
+		if option.Level != nil {
+			return *option.Level
+		}
+		return atomicLevel
+	}
+
+	mux := http.NewServeMux()
+	handle := func(urlPath, target string, handler http.Handler, snapshot func() interface{}) {
+		mux.HandleFunc(urlPath, func(w http.ResponseWriter, r *http.Request) {
+			// 请求还没有认证，header 中的 trace id 不合法时截断后记录在单独的字段中并生成新的 trace id
+			logger := CloneLogger("atomiclevel")
+			traceID := r.Header.Get(string(TraceIDKeyname))
+			if traceID != "" && !validTraceID(traceID, GetTraceIDValidator()) {
+				logger = logger.With(zap.String(string(InvalidTraceIDKeyname), truncateTraceID(traceID)))
+				traceID = ""
+			}
+			_, logger = NewCtxLogger(r.Context(), logger, traceID)
+			logger = logger.With(zap.String("remote_addr", r.RemoteAddr), zap.String("method", r.Method), zap.String("target", target))
+			// 先校验 IP 和认证信息，未通过时不处理请求
+			if !ipAllowed(allowNets, r.RemoteAddr) {
+				logger.Warn("logging AtomicLevel server forbidden ip")
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			user, ok := authenticate(r, option.AtomicLevelServerOption)
+			if !ok {
+				logger.Warn("logging AtomicLevel server unauthorized request")
+				w.Header().Set("WWW-Authenticate", `Basic realm="logging"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if r.Method == http.MethodGet || snapshot == nil {
+				handler.ServeHTTP(w, r)
+				return
+			}
+			// 记录修改前后的状态
+			from := snapshot()
+			rw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			handler.ServeHTTP(rw, r)
+			logger.Warn("logging AtomicLevel server audit",
+				zap.String("user", user),
+				zap.Int("status_code", rw.status),
+				zap.Any("from", from),
+				zap.Any("to", snapshot()),
+			)
+		})
+	}
+	levelSnapshot := func() interface{} { return level().Level().String() }
+	// 每次请求时获取 atomic level ，默认 logger 的 atomic level 可能被 NewLogger 替换
+	handle("/", "atomic level", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		levelHandler(level()).ServeHTTP(w, r)
+	}), levelSnapshot)
+	// curl -X GET http://host:port/names
+	// curl -X PUT http://host:port/names -d '{"name":"logging.gorm","level":"debug"}'
+	handle("/names", "named levels", http.HandlerFunc(namedLevelHandler), func() interface{} { return NamedLevels() })
+	// curl -X PUT http://host:port/force_debug -d '{"trace_id":"xxx","ttl":"10m"}'
+	handle("/force_debug", "force debug trace ids", http.HandlerFunc(forceDebugHandler), func() interface{} { return ForceDebugTraceIDs() })
+	handle("/options", "options", readOnlyHandler(func(*http.Request) (interface{}, int) {
+		rwMutex.RLock()
+		defer rwMutex.RUnlock()
+		return newOptionsView(globalOptions, level().Level()), http.StatusOK
+	}), nil)
+	handle("/sinks", "sinks", readOnlyHandler(func(*http.Request) (interface{}, int) {
+		rwMutex.RLock()
+		defer rwMutex.RUnlock()
+		outputPaths := globalOptions.OutputPaths
+		if len(outputPaths) == 0 {
+			outputPaths = outPaths
+		}
+		if len(globalOptions.Outputs) > 0 {
+			outputPaths = outputsPaths(globalOptions.Outputs)
+		}
+		outputPaths = append([]string{}, outputPaths...)
+		for _, route := range globalOptions.Routes {
+			outputPaths = append(outputPaths, route.OutputPaths...)
+		}
+		return sinkStatuses(outputPaths), http.StatusOK
+	}), nil)
+	// RecentLogs 实现了 http.Handler 时（如 RingCore ）由其处理查询条件和 SSE
+	if h, ok := option.RecentLogs.(http.Handler); ok {
+		handle("/logs", "recent logs", h, nil)
+	} else {
+		handle("/logs", "recent logs", readOnlyHandler(func(r *http.Request) (interface{}, int) {
+			if option.RecentLogs == nil {
+				return map[string]string{"error": "recent logs is not enabled"}, http.StatusNotFound
+			}
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			return option.RecentLogs.RecentLogs(limit), http.StatusOK
+		}), nil)
+	}
+	return mux, nil
+}
+
+// GinAdminRoutes 在 gin 的路由组上挂载日志管理接口
+// logging.GinAdminRoutes(app.Group("/logging"), logging.AdminOption{})
+func GinAdminRoutes(group *gin.RouterGroup, option AdminOption) error {
+	handler, err := AdminHandler(option)
+	if err != nil {
+		return err
+	}
+	handler = stripPathPrefix(group.BasePath(), handler)
+	group.Any("", gin.WrapH(handler))
+	group.Any("/*path", gin.WrapH(handler))
+	return nil
+}
+
+// stripPathPrefix 去掉请求 path 的前缀后交给 handler 处理，去掉后为空时使用 /
+func stripPathPrefix(prefix string, handler http.Handler) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, prefix)
+		if len(p) == len(r.URL.Path) || (p != "" && p[0] != '/') {
+			http.NotFound(w, r)
+			return
+		}
+		if p == "" {
+			p = "/"
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = p
+		r2.URL.RawPath = ""
+		handler.ServeHTTP(w, r2)
+	})
+}
+
+// readOnlyHandler 只支持 GET 请求的 json 接口
+func readOnlyHandler(get func(*http.Request) (interface{}, int)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			jsoniter.NewEncoder(w).Encode(map[string]string{"error": "only GET is supported"})
+			return
+		}
+		body, code := get(r)
+		w.WriteHeader(code)
+		jsoniter.NewEncoder(w).Encode(body)
+	})
+}
+
+// optionsView 管理接口中展示的配置，不包含密码等敏感信息
+type optionsView struct {
+	Name              string                 `json:"name"`
+	Level             string                 `json:"level"`
+	Format            string                 `json:"format"`
+	OutputPaths       []string               `json:"output_paths"`
+	InitialFields     map[string]interface{} `json:"initial_fields,omitempty"`
+	DisableCaller     bool                   `json:"disable_caller"`
+	DisableStacktrace bool                   `json:"disable_stacktrace"`
+	Sentry            bool                   `json:"sentry"`
+	SentryTags        map[string]string      `json:"sentry_tags,omitempty"`
+	Lumberjack        *LumberjackConfig      `json:"lumberjack,omitempty"`
+	AtomicLevelServer map[string]interface{} `json:"atomic_level_server,omitempty"`
+	Redact            bool                   `json:"redact"`
+	Sampling          *SamplingConfig        `json:"sampling,omitempty"`
+	Async             *AsyncConfig           `json:"async,omitempty"`
+	SyncPolicy        *SyncPolicyConfig      `json:"sync_policy,omitempty"`
+	Routes            []RouteConfig          `json:"routes,omitempty"`
+	Outputs           []OutputConfig         `json:"outputs,omitempty"`
+}
+
+func newOptionsView(options Options, level zapcore.Level) optionsView {
+	view := optionsView{
+		Name:              options.Name,
+		Level:             level.String(),
+		Format:            options.Format,
+		OutputPaths:       options.OutputPaths,
+		InitialFields:     options.InitialFields,
+		DisableCaller:     options.DisableCaller,
+		DisableStacktrace: options.DisableStacktrace,
+		Sentry:            options.SentryClient != nil,
+		SentryTags:        options.SentryTags,
+		Redact:            options.Redactor != nil,
+	}
+	if sink := options.LumberjackSink; sink != nil {
+		view.Lumberjack = &LumberjackConfig{
+			Scheme:     sink.Scheme,
+			Filename:   sink.Filename,
+			MaxSize:    sink.MaxSize,
+			MaxAge:     sink.MaxAge,
+			MaxBackups: sink.MaxBackups,
+			Compress:   sink.Compress,
+			LocalTime:  sink.LocalTime,
+		}
+	}
+	if server := options.AtomicLevelServer; server.Addr != "" {
+		view.AtomicLevelServer = map[string]interface{}{
+			"addr":         server.Addr,
+			"path":         server.Path,
+			"basic_auth":   server.Username != "",
+			"bearer_token": server.BearerToken != "",
+			"tls":          server.TLSCertFile != "",
+			"allow_ips":    server.AllowIPs,
+		}
+	}
+	if sampling := options.Sampling; sampling != nil {
+		view.Sampling = &SamplingConfig{
+			Disable:              sampling.Disable,
+			Tick:                 sampling.Tick.String(),
+			Initial:              sampling.Initial,
+			Thereafter:           sampling.Thereafter,
+			Levels:               sampling.Levels,
+			DisableErrorAndAbove: sampling.DisableErrorAndAbove,
+		}
+	}
+	if async := options.Async; async != nil {
+		view.Async = &AsyncConfig{
+			BufferSize:    async.BufferSize,
+			FlushInterval: async.FlushInterval.String(),
+			FlushSize:     async.FlushSize,
+			DropWhenFull:  async.DropWhenFull,
+		}
+	}
+	if policy := options.SyncPolicy; policy != nil {
+		view.SyncPolicy = &SyncPolicyConfig{Interval: policy.Interval.String(), Level: policy.Level}
+	}
+	for _, route := range options.Routes {
+		view.Routes = append(view.Routes, RouteConfig{
+			LoggerNames: route.LoggerNames,
+			Level:       route.Level,
+			Format:      route.Format,
+			OutputPaths: route.OutputPaths,
+			Exclusive:   route.Exclusive,
+		})
+	}
+	for _, output := range options.Outputs {
+		view.Outputs = append(view.Outputs, OutputConfig{Path: output.Path, Format: output.Format, Color: output.Color})
+	}
+	return view
+}
+
+// sinkStatus 日志输出的状态
+type sinkStatus struct {
+	Path     string     `json:"path"`
+	Type     string     `json:"type"` // std, file, lumberjack, other
+	Filename string     `json:"filename,omitempty"`
+	Size     int64      `json:"size,omitempty"`
+	ModTime  *time.Time `json:"mod_time,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// sinkStatuses 返回日志输出的状态，文件类型的输出会返回文件大小和修改时间
+func sinkStatuses(outputPaths []string) []sinkStatus {
+	if len(outputPaths) == 0 {
+		outputPaths = outPaths
+	}
+	statuses := make([]sinkStatus, 0, len(outputPaths))
+	for _, p := range outputPaths {
+		status := sinkStatus{Path: p, Type: "other"}
+		switch {
+		case p == "stdout" || p == "stderr":
+			status.Type = "std"
+		case filepath.IsAbs(p) || !strings.Contains(p, "://"):
+			status.Type, status.Filename = "file", p
+		case strings.HasPrefix(p, "file://"):
+			status.Type, status.Filename = "file", strings.TrimPrefix(p, "file://")
+		default:
+			scheme, _, _ := strings.Cut(p, "://")
+			lumberjackSinksMutex.Lock()
+			if sink, exists := lumberjackSinks[scheme]; exists {
+				status.Type, status.Filename = "lumberjack", sink.Filename
+			}
+			lumberjackSinksMutex.Unlock()
+		}
+		if status.Filename != "" {
+			if info, err := os.Stat(status.Filename); err != nil {
+				status.Error = err.Error()
+			} else {
+				modTime := info.ModTime()
+				status.Size, status.ModTime = info.Size(), &modTime
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type testRecentLogs []LogEntry
+
+func (l testRecentLogs) RecentLogs(limit int) []LogEntry {
+	if limit > 0 && limit < len(l) {
+		return l[len(l)-limit:]
+	}
+	return l
+}
+
+func TestAdminHandler(t *testing.T) {
+	restoreGlobalLogger(t)
+	path := filepath.Join(t.TempDir(), "admin.log")
+	err := ReloadLogger(Options{
+		Name:              "admin",
+		OutputPaths:       []string{path},
+		AtomicLevelServer: AtomicLevelServerOption{Addr: ":0", Password: "secret", Username: "admin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Info(nil, "admin test")
+
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	handler, err := AdminHandler(AdminOption{
+		Level:      &level,
+		RecentLogs: testRecentLogs{{Message: "first"}, {Message: "second"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(h http.Handler, urlPath string) (int, string) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, urlPath, nil))
+		return w.Code, w.Body.String()
+	}
+
+	if code, body := get(handler, "/"); code != http.StatusOK || !strings.Contains(body, `"level":"info"`) {
+		t.Fatal("get level failed", code, body)
+	}
+	code, body := get(handler, "/options")
+	if code != http.StatusOK || !strings.Contains(body, `"name":"admin"`) || !strings.Contains(body, `"basic_auth":true`) {
+		t.Fatal("get options failed", code, body)
+	}
+	if strings.Contains(body, "secret") {
+		t.Fatal("options should not contain password", body)
+	}
+	code, body = get(handler, "/sinks")
+	var sinks []sinkStatus
+	if err := jsoniter.UnmarshalFromString(body, &sinks); err != nil || code != http.StatusOK {
+		t.Fatal("get sinks failed", code, body)
+	}
+	if len(sinks) != 1 || sinks[0].Type != "file" || sinks[0].Size == 0 {
+		t.Fatal("invalid sinks", body)
+	}
+	if code, body := get(handler, "/logs?limit=1"); code != http.StatusOK || strings.Contains(body, "first") || !strings.Contains(body, "second") {
+		t.Fatal("get logs failed", code, body)
+	}
+
+	noLogsHandler, _ := AdminHandler(AdminOption{})
+	if code, _ := get(noLogsHandler, "/logs"); code != http.StatusNotFound {
+		t.Fatal("logs should be 404 without recent logs source", code)
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	if err := GinAdminRoutes(app.Group("/admin/logging"), AdminOption{Level: &level}); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := get(app, "/admin/logging"); code != http.StatusOK || !strings.Contains(body, `"level":"info"`) {
+		t.Fatal("gin get level failed", code, body)
+	}
+	if code, body := get(app, "/admin/logging/names"); code != http.StatusOK || !strings.Contains(body, "{") {
+		t.Fatal("gin get names failed", code, body)
+	}
+}
+
+func TestStartSharedAtomicLevelServer(t *testing.T) {
+	options := AtomicLevelServerOption{Addr: "127.0.0.1:0", AllowIPs: []string{}}
+	first, err := startSharedAtomicLevelServer(options, nil, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseSharedAtomicLevelServer(context.Background(), options.Addr, first)
+	second, err := startSharedAtomicLevelServer(AtomicLevelServerOption{Addr: options.Addr}, nil, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseSharedAtomicLevelServer(context.Background(), options.Addr, second)
+	if first != second {
+		t.Fatal("server with same addr should be shared")
+	}
+	_, handle, err := NewLoggerWithHandle(Options{AtomicLevelServer: options})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close(context.Background())
+	if handle.server != first {
+		t.Fatal("NewLogger should use the shared server")
+	}
+
+	// 相同地址使用不同的安全配置时返回 error
+	if _, err := startSharedAtomicLevelServer(AtomicLevelServerOption{Addr: options.Addr, BearerToken: "token"}, nil, zap.NewNop()); err == nil {
+		t.Fatal("same addr with different options should return error")
+	}
+	rsp, err := http.Get("http://" + first.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatal("shared server should not require auth", rsp.StatusCode)
+	}
+}
+
+func TestAdminHandlerInvalidTraceID(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+	handler, err := AdminHandler(AdminOption{AtomicLevelServerOption: AtomicLevelServerOption{Username: "admin", Password: "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(string(TraceIDKeyname), "evil\tid")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatal("request without auth should be unauthorized", w.Code)
+	}
+	// 未认证的请求中不合法的 trace id 不会作为日志的 trace id
+	entries := logs.AllUntimed()
+	if len(entries) != 1 {
+		t.Fatal("invalid log count", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if traceID, _ := fields[string(TraceIDKeyname)].(string); !IsSafeTraceID(traceID) || fields[string(InvalidTraceIDKeyname)] != "evil\tid" {
+		t.Fatal("invalid trace id should be replaced", fields)
+	}
+}
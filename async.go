@@ -0,0 +1,183 @@
+// 异步写日志
+// AsyncWriteSyncer 将日志写入有界队列后立即返回，由后台 goroutine 批量写入日志输出，
+// 队列满时可以选择阻塞等待或丢弃日志， Sync 和 Close 时会等待队列中的日志全部写入
+
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	promAsyncDroppedCount = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "async_dropped_count",
+			Help:      "log entries dropped by async write syncer when the queue is full",
+		},
+	)
+)
+
+const (
+	// 默认异步写配置
+	defaultAsyncBufferSize    = 8192
+	defaultAsyncFlushInterval = time.Second
+	defaultAsyncFlushSize     = 256 << 10
+)
+
+// AsyncOption 异步写日志配置
+type AsyncOption struct {
+	BufferSize    int           // 队列中最多缓存的日志条数，默认 8192
+	FlushInterval time.Duration // 定时写入日志输出的间隔，默认 1s
+	FlushSize     int           // 缓存的日志字节数达到该值时写入日志输出，默认 256KB
+	DropWhenFull  bool          // 队列满时是否丢弃日志，默认阻塞等待，丢弃的日志数量会记录到 prometheus 指标中
+}
+
+// asyncItem 队列中的日志或 Sync 请求
+type asyncItem struct {
+	data []byte
+	// 不为 nil 时为 Sync 请求，写入之前的日志后关闭
+	done chan error
+}
+
+// AsyncWriteSyncer 异步写日志的 WriteSyncer
+type AsyncWriteSyncer struct {
+	ws     zapcore.WriteSyncer
+	option AsyncOption
+	queue  chan asyncItem
+	// 关闭时加写锁，保证关闭后没有写入队列的日志
+	closeMutex sync.RWMutex
+	closed     bool
+	stopped    chan struct{}
+	// 关闭后直接写入 ws
+	directMutex sync.Mutex
+	dropped     atomic.Uint64
+}
+
+// NewAsyncWriteSyncer 创建异步写入 ws 的 WriteSyncer
+func NewAsyncWriteSyncer(ws zapcore.WriteSyncer, option AsyncOption) *AsyncWriteSyncer {
+	if option.BufferSize <= 0 {
+		option.BufferSize = defaultAsyncBufferSize
+	}
+	if option.FlushInterval <= 0 {
+		option.FlushInterval = defaultAsyncFlushInterval
+	}
+	if option.FlushSize <= 0 {
+		option.FlushSize = defaultAsyncFlushSize
+	}
+	s := &AsyncWriteSyncer{
+		ws:      ws,
+		option:  option,
+		queue:   make(chan asyncItem, option.BufferSize),
+		stopped: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Write 复制 p 并写入队列，关闭后直接写入日志输出
+func (s *AsyncWriteSyncer) Write(p []byte) (int, error) {
+	s.closeMutex.RLock()
+	defer s.closeMutex.RUnlock()
+	if s.closed {
+		s.directMutex.Lock()
+		defer s.directMutex.Unlock()
+		return s.ws.Write(p)
+	}
+	// zap 会复用 p ，需要复制后再写入队列
+	item := asyncItem{data: append([]byte(nil), p...)}
+	if !s.option.DropWhenFull {
+		s.queue <- item
+		return len(p), nil
+	}
+	select {
+	case s.queue <- item:
+	default:
+		s.dropped.Add(1)
+		promAsyncDroppedCount.Inc()
+	}
+	return len(p), nil
+}
+
+// Sync 等待队列中已有的日志全部写入并 Sync 日志输出
+// zap 打印 Panic 及以上级别的日志时会调用 Sync ，保证进程退出前日志已经写入
+func (s *AsyncWriteSyncer) Sync() error {
+	s.closeMutex.RLock()
+	defer s.closeMutex.RUnlock()
+	if s.closed {
+		s.directMutex.Lock()
+		defer s.directMutex.Unlock()
+		return s.ws.Sync()
+	}
+	done := make(chan error, 1)
+	s.queue <- asyncItem{done: done}
+	return <-done
+}
+
+// Close 写入队列中的全部日志后停止后台 goroutine ，之后的日志直接写入日志输出
+func (s *AsyncWriteSyncer) Close() error {
+	s.closeMutex.Lock()
+	if s.closed {
+		s.closeMutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.closeMutex.Unlock()
+	<-s.stopped
+	s.directMutex.Lock()
+	defer s.directMutex.Unlock()
+	return s.ws.Sync()
+}
+
+// Dropped 返回队列满时丢弃的日志数量
+func (s *AsyncWriteSyncer) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// run 从队列中读取日志，缓存达到 FlushSize 或定时写入日志输出
+func (s *AsyncWriteSyncer) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.option.FlushInterval)
+	defer ticker.Stop()
+	var buf bytes.Buffer
+	var writeErr error
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		if _, err := s.ws.Write(buf.Bytes()); err != nil {
+			writeErr = err
+		}
+		buf.Reset()
+	}
+	for {
+		select {
+		case item, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if item.done != nil {
+				flush()
+				item.done <- errors.Join(writeErr, s.ws.Sync())
+				writeErr = nil
+				continue
+			}
+			buf.Write(item.data)
+			if buf.Len() >= s.option.FlushSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package logging
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// testWriteSyncer 记录写入和 Sync 次数，可以阻塞写入
+type testWriteSyncer struct {
+	mutex  sync.Mutex
+	buf    bytes.Buffer
+	syncs  int
+	writes int
+	block  chan struct{}
+}
+
+func (w *testWriteSyncer) Write(p []byte) (int, error) {
+	if w.block != nil {
+		<-w.block
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.writes++
+	return w.buf.Write(p)
+}
+
+func (w *testWriteSyncer) Sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.syncs++
+	return nil
+}
+
+func (w *testWriteSyncer) String() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncWriteSyncer(t *testing.T) {
+	ws := &testWriteSyncer{}
+	async := NewAsyncWriteSyncer(ws, AsyncOption{FlushInterval: time.Hour})
+	async.Write([]byte("a\n"))
+	async.Write([]byte("b\n"))
+	if ws.String() != "" {
+		t.Fatal("async write should be buffered", ws.String())
+	}
+	if err := async.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if ws.String() != "a\nb\n" || ws.writes != 1 || ws.syncs != 1 {
+		t.Fatal("sync should drain queue in one write", ws.String(), ws.writes, ws.syncs)
+	}
+
+	async.Write([]byte("c\n"))
+	if err := async.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ws.String() != "a\nb\nc\n" {
+		t.Fatal("close should drain queue", ws.String())
+	}
+	async.Write([]byte("d\n"))
+	if ws.String() != "a\nb\nc\nd\n" {
+		t.Fatal("write after close should write directly", ws.String())
+	}
+}
+
+func TestAsyncWriteSyncerFlush(t *testing.T) {
+	ws := &testWriteSyncer{}
+	async := NewAsyncWriteSyncer(ws, AsyncOption{FlushInterval: 10 * time.Millisecond, FlushSize: 4})
+	defer async.Close()
+	async.Write([]byte("abcd"))
+	async.Write([]byte("e"))
+	time.Sleep(100 * time.Millisecond)
+	if ws.String() != "abcde" {
+		t.Fatal("async write should flush by size and interval", ws.String())
+	}
+}
+
+func TestAsyncWriteSyncerDrop(t *testing.T) {
+	ws := &testWriteSyncer{block: make(chan struct{})}
+	async := NewAsyncWriteSyncer(ws, AsyncOption{BufferSize: 1, FlushSize: 1, DropWhenFull: true})
+	for i := 0; i < 10; i++ {
+		async.Write([]byte("x"))
+	}
+	if async.Dropped() == 0 {
+		t.Fatal("full queue should drop entries")
+	}
+	close(ws.block)
+	async.Close()
+}
+
+func TestAsyncLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "async.log")
+	logger, closer, err := buildLogger(Options{
+		OutputPaths: []string{path},
+		Async:       &AsyncOption{FlushInterval: time.Hour},
+	}, zap.NewAtomicLevelAt(zap.DebugLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("async")
+	if content := readLogFile(t, path); content != "" {
+		t.Fatal("async log should be buffered", content)
+	}
+	// Panic 及以上级别的日志会 Sync
+	func() {
+		defer func() { recover() }()
+		logger.Panic("panic")
+	}()
+	if content := readLogFile(t, path); !strings.Contains(content, "async") || !strings.Contains(content, "panic") {
+		t.Fatal("panic should drain async queue", content)
+	}
+	logger.Info("closed")
+	closer()
+	if content := readLogFile(t, path); !strings.Contains(content, "closed") {
+		t.Fatal("close should drain async queue", content)
+	}
+}
@@ -0,0 +1,556 @@
+// 从配置文件和环境变量中加载 logger 配置
+// 优先级：环境变量 > 配置文件 > 默认值
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pelletier/go-toml/v2"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultConfigEnvPrefix 默认的配置环境变量前缀，如 LOGGING_LEVEL
+	DefaultConfigEnvPrefix = "LOGGING"
+)
+
+// Config 可以从配置文件和环境变量中加载的 logger 配置，调用 Options 方法转换为 NewLogger 使用的 Options
+//
+// 环境变量名为前缀加上 json tag 路径的大写形式，以 _ 连接，例如：
+// LOGGING_LEVEL=info
+// LOGGING_OUTPUT_PATHS=stdout,lumberjack://localhost
+// LOGGING_INITIAL_FIELDS=service=demo,env=prod
+// LOGGING_SENTRY_TAGS=service=demo
+// LOGGING_LUMBERJACK_MAX_SIZE=100
+// LOGGING_ATOMIC_LEVEL_SERVER_ADDR=:1903
+type Config struct {
+	Name              string                   `json:"name" yaml:"name" toml:"name"`                                              // logger 名称
+	Level             string                   `json:"level" yaml:"level" toml:"level"`                                           // 日志级别 debug, info, warn, error dpanic, panic, fatal
+	Format            string                   `json:"format" yaml:"format" toml:"format"`                                        // 日志格式 json, console
+	OutputPaths       []string                 `json:"output_paths" yaml:"output_paths" toml:"output_paths"`                      // 日志输出位置
+	InitialFields     map[string]interface{}   `json:"initial_fields" yaml:"initial_fields" toml:"initial_fields"`                // 日志初始字段
+	DisableCaller     bool                     `json:"disable_caller" yaml:"disable_caller" toml:"disable_caller"`                // 是否关闭打印 caller
+	DisableStacktrace bool                     `json:"disable_stacktrace" yaml:"disable_stacktrace" toml:"disable_stacktrace"`    // 是否关闭打印 stackstrace
+	SentryDSN         string                   `json:"sentry_dsn" yaml:"sentry_dsn" toml:"sentry_dsn"`                            // sentry dsn ，不为空时创建 sentry client
+	SentryDebug       bool                     `json:"sentry_debug" yaml:"sentry_debug" toml:"sentry_debug"`                      // sentry debug 模式
+	SentryTags        map[string]string        `json:"sentry_tags" yaml:"sentry_tags" toml:"sentry_tags"`                         // 上报 sentry 时添加的 tags
+	EncoderConfig     *EncoderKeysConfig       `json:"encoder_config" yaml:"encoder_config" toml:"encoder_config"`                // 日志字段 key 的名称
+	Lumberjack        *LumberjackConfig        `json:"lumberjack" yaml:"lumberjack" toml:"lumberjack"`                            // lumberjack 日志文件 rotate 配置
+	AtomicLevelServer *AtomicLevelServerOption `json:"atomic_level_server" yaml:"atomic_level_server" toml:"atomic_level_server"` // AtomicLevel server 相关配置
+	Sampling          *SamplingConfig          `json:"sampling" yaml:"sampling" toml:"sampling"`                                  // 日志采样配置
+	Redact            *RedactorConfig          `json:"redact" yaml:"redact" toml:"redact"`                                        // 日志字段脱敏配置
+	Async             *AsyncConfig             `json:"async" yaml:"async" toml:"async"`                                           // 异步写日志配置
+	SyncPolicy        *SyncPolicyConfig        `json:"sync_policy" yaml:"sync_policy" toml:"sync_policy"`                         // 日志输出的 Sync 策略
+	Routes            []RouteConfig            `json:"routes" yaml:"routes" toml:"routes"`                                        // 日志路由规则，只支持从配置文件加载
+	Outputs           []OutputConfig           `json:"outputs" yaml:"outputs" toml:"outputs"`                                     // 每个日志输出单独设置日志格式，只支持从配置文件加载
+}
+
+// EncoderKeysConfig 日志字段 key 名称和编码方式配置，为空的配置项使用默认 EncoderConfig 中的值
+type EncoderKeysConfig struct {
+	TimeKey         string `json:"time_key" yaml:"time_key" toml:"time_key"`
+	LevelKey        string `json:"level_key" yaml:"level_key" toml:"level_key"`
+	NameKey         string `json:"name_key" yaml:"name_key" toml:"name_key"`
+	CallerKey       string `json:"caller_key" yaml:"caller_key" toml:"caller_key"`
+	FunctionKey     string `json:"function_key" yaml:"function_key" toml:"function_key"`
+	MessageKey      string `json:"message_key" yaml:"message_key" toml:"message_key"`
+	StacktraceKey   string `json:"stacktrace_key" yaml:"stacktrace_key" toml:"stacktrace_key"`
+	LevelEncoder    string `json:"level_encoder" yaml:"level_encoder" toml:"level_encoder"`          // capital, capitalColor, lowercase, color
+	TimeEncoder     string `json:"time_encoder" yaml:"time_encoder" toml:"time_encoder"`             // default, rfc3339nano, rfc3339, iso8601, epoch, millis, nanos
+	DurationEncoder string `json:"duration_encoder" yaml:"duration_encoder" toml:"duration_encoder"` // seconds, string, nanos, ms
+}
+
+// LumberjackConfig lumberjack sink 配置
+type LumberjackConfig struct {
+	Scheme     string `json:"scheme" yaml:"scheme" toml:"scheme"`                // 在 OutputPaths 中使用 scheme:// 指定输出到 lumberjack
+	Filename   string `json:"filename" yaml:"filename" toml:"filename"`          // 日志文件名，默认为 LogFilename
+	MaxSize    int    `json:"max_size" yaml:"max_size" toml:"max_size"`          // 单个日志文件最大 MB
+	MaxAge     int    `json:"max_age" yaml:"max_age" toml:"max_age"`             // 日志文件最多保留天数
+	MaxBackups int    `json:"max_backups" yaml:"max_backups" toml:"max_backups"` // 最多保留的日志文件个数
+	Compress   bool   `json:"compress" yaml:"compress" toml:"compress"`          // 是否压缩 rotate 后的日志文件
+	LocalTime  bool   `json:"localtime" yaml:"localtime" toml:"localtime"`       // rotate 后的文件名是否使用本地时间
+}
+
+// SamplingConfig 日志采样配置，对应 SamplingOption
+type SamplingConfig struct {
+	Disable              bool                           `json:"disable" yaml:"disable" toml:"disable"`
+	Tick                 string                         `json:"tick" yaml:"tick" toml:"tick"` // time.ParseDuration 格式，如 1s
+	Initial              int                            `json:"initial" yaml:"initial" toml:"initial"`
+	Thereafter           int                            `json:"thereafter" yaml:"thereafter" toml:"thereafter"`
+	Levels               map[string]SamplingLevelOption `json:"levels" yaml:"levels" toml:"levels"`
+	DisableErrorAndAbove bool                           `json:"disable_error_and_above" yaml:"disable_error_and_above" toml:"disable_error_and_above"`
+}
+
+// AsyncConfig 异步写日志配置，对应 AsyncOption
+type AsyncConfig struct {
+	BufferSize    int    `json:"buffer_size" yaml:"buffer_size" toml:"buffer_size"`
+	FlushInterval string `json:"flush_interval" yaml:"flush_interval" toml:"flush_interval"` // time.ParseDuration 格式，如 1s
+	FlushSize     int    `json:"flush_size" yaml:"flush_size" toml:"flush_size"`
+	DropWhenFull  bool   `json:"drop_when_full" yaml:"drop_when_full" toml:"drop_when_full"`
+}
+
+// SyncPolicyConfig 日志输出的 Sync 策略，对应 SyncPolicy
+type SyncPolicyConfig struct {
+	Interval string `json:"interval" yaml:"interval" toml:"interval"` // time.ParseDuration 格式，如 1s
+	Level    string `json:"level" yaml:"level" toml:"level"`
+}
+
+// RouteConfig 日志路由规则，对应 Route
+type RouteConfig struct {
+	LoggerNames []string          `json:"logger_names" yaml:"logger_names" toml:"logger_names"`
+	Level       string            `json:"level" yaml:"level" toml:"level"`
+	Format      string            `json:"format" yaml:"format" toml:"format"`
+	OutputPaths []string          `json:"output_paths" yaml:"output_paths" toml:"output_paths"`
+	Lumberjack  *LumberjackConfig `json:"lumberjack" yaml:"lumberjack" toml:"lumberjack"`
+	Exclusive   bool              `json:"exclusive" yaml:"exclusive" toml:"exclusive"`
+}
+
+// OutputConfig 日志输出配置，对应 Output
+type OutputConfig struct {
+	Path          string             `json:"path" yaml:"path" toml:"path"`
+	Format        string             `json:"format" yaml:"format" toml:"format"`
+	EncoderConfig *EncoderKeysConfig `json:"encoder_config" yaml:"encoder_config" toml:"encoder_config"` // 为空时使用 encoder_config ，设置时在 encoder_config 的基础上设置
+	Color         string             `json:"color" yaml:"color" toml:"color"`                            // auto, always, never
+}
+
+var (
+	configLevelEncoders = map[string]zapcore.LevelEncoder{
+		"capital":      zapcore.CapitalLevelEncoder,
+		"capitalColor": zapcore.CapitalColorLevelEncoder,
+		"lowercase":    zapcore.LowercaseLevelEncoder,
+		"color":        zapcore.LowercaseColorLevelEncoder,
+	}
+	configTimeEncoders = map[string]zapcore.TimeEncoder{
+		"default":     TimeEncoder,
+		"rfc3339nano": zapcore.RFC3339NanoTimeEncoder,
+		"rfc3339":     zapcore.RFC3339TimeEncoder,
+		"iso8601":     zapcore.ISO8601TimeEncoder,
+		"epoch":       zapcore.EpochTimeEncoder,
+		"millis":      zapcore.EpochMillisTimeEncoder,
+		"nanos":       zapcore.EpochNanosTimeEncoder,
+	}
+	configDurationEncoders = map[string]zapcore.DurationEncoder{
+		"seconds": zapcore.SecondsDurationEncoder,
+		"string":  zapcore.StringDurationEncoder,
+		"nanos":   zapcore.NanosDurationEncoder,
+		"ms":      zapcore.MillisDurationEncoder,
+	}
+)
+
+// LoadConfig 从配置文件和指定前缀的环境变量中加载配置，环境变量优先级高于配置文件
+// path 为空时只从环境变量加载，根据文件扩展名选择 .yaml/.yml 、 .json 、 .toml 格式
+// envPrefix 为空时使用 DefaultConfigEnvPrefix
+func LoadConfig(path, envPrefix string) (*Config, error) {
+	config := &Config{}
+	if path != "" {
+		if err := config.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if envPrefix == "" {
+		envPrefix = DefaultConfigEnvPrefix
+	}
+	if err := loadEnv(reflect.ValueOf(config).Elem(), envPrefix); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadOptions 从配置文件和环境变量中加载配置并转换为 Options
+func LoadOptions(path, envPrefix string) (Options, error) {
+	config, err := LoadConfig(path, envPrefix)
+	if err != nil {
+		return Options{}, err
+	}
+	return config.Options()
+}
+
+// loadFile 根据扩展名解析配置文件
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file error: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, c)
+	case ".json":
+		err = jsoniter.Unmarshal(content, c)
+	case ".toml":
+		err = toml.Unmarshal(content, c)
+	default:
+		return fmt.Errorf("unsupported config file type: %s", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s error: %w", path, err)
+	}
+	return nil
+}
+
+// Validate 校验配置，返回全部不合法的配置项
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Level != "" {
+		if _, exists := AtomicLevelMap[strings.ToLower(c.Level)]; !exists {
+			errs = append(errs, fmt.Errorf("invalid level: %s", c.Level))
+		}
+	}
+	if c.Format != "" && c.Format != "json" && c.Format != "console" {
+		errs = append(errs, fmt.Errorf("invalid format: %s, must be json or console", c.Format))
+	}
+	if ec := c.EncoderConfig; ec != nil {
+		errs = append(errs, ec.validate("encoder_config")...)
+	}
+	if lj := c.Lumberjack; lj != nil {
+		if lj.Scheme == "" {
+			errs = append(errs, errors.New("lumberjack.scheme is required"))
+		} else if !c.usesScheme(lj.Scheme) {
+			errs = append(errs, fmt.Errorf("lumberjack.scheme %s is not used in output_paths or outputs", lj.Scheme))
+		}
+		if lj.MaxSize < 0 || lj.MaxAge < 0 || lj.MaxBackups < 0 {
+			errs = append(errs, errors.New("lumberjack max_size, max_age and max_backups must not be negative"))
+		}
+	}
+	if s := c.Sampling; s != nil {
+		if s.Tick != "" {
+			if _, err := time.ParseDuration(s.Tick); err != nil {
+				errs = append(errs, fmt.Errorf("invalid sampling.tick: %w", err))
+			}
+		}
+		if _, err := NewSamplingCore(zapcore.NewNopCore(), c.samplingOption()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.Redact != nil {
+		if _, err := NewRedactor(*c.Redact); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if a := c.Async; a != nil {
+		if a.FlushInterval != "" {
+			if _, err := time.ParseDuration(a.FlushInterval); err != nil {
+				errs = append(errs, fmt.Errorf("invalid async.flush_interval: %w", err))
+			}
+		}
+		if a.BufferSize < 0 || a.FlushSize < 0 {
+			errs = append(errs, errors.New("async buffer_size and flush_size must not be negative"))
+		}
+	}
+	if p := c.SyncPolicy; p != nil {
+		if p.Interval != "" {
+			if _, err := time.ParseDuration(p.Interval); err != nil {
+				errs = append(errs, fmt.Errorf("invalid sync_policy.interval: %w", err))
+			}
+		}
+		if _, exists := ZapcoreLevelMap[strings.ToLower(p.Level)]; p.Level != "" && !exists {
+			errs = append(errs, fmt.Errorf("invalid sync_policy.level: %s", p.Level))
+		}
+	}
+	for i, r := range c.Routes {
+		if len(r.OutputPaths) == 0 {
+			errs = append(errs, fmt.Errorf("routes[%d].output_paths is required", i))
+		}
+		if _, exists := ZapcoreLevelMap[strings.ToLower(r.Level)]; r.Level != "" && !exists {
+			errs = append(errs, fmt.Errorf("invalid routes[%d].level: %s", i, r.Level))
+		}
+		if f := strings.ToLower(r.Format); f != "" && f != "json" && f != "console" {
+			errs = append(errs, fmt.Errorf("invalid routes[%d].format: %s", i, r.Format))
+		}
+		if lj := r.Lumberjack; lj != nil && !usesScheme(r.OutputPaths, lj.Scheme) {
+			errs = append(errs, fmt.Errorf("routes[%d].lumberjack.scheme %s is not used in output_paths", i, lj.Scheme))
+		}
+	}
+	for i, o := range c.Outputs {
+		if err := validateOutput(Output{Path: o.Path, Format: o.Format, Color: o.Color}); err != nil {
+			errs = append(errs, fmt.Errorf("invalid outputs[%d]: %w", i, err))
+		}
+		if ec := o.EncoderConfig; ec != nil {
+			errs = append(errs, ec.validate(fmt.Sprintf("outputs[%d].encoder_config", i))...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// usesScheme 判断 OutputPaths 或 Outputs 中是否使用了 scheme
+func (c *Config) usesScheme(scheme string) bool {
+	for _, o := range c.Outputs {
+		if usesScheme([]string{o.Path}, scheme) {
+			return true
+		}
+	}
+	return usesScheme(c.OutputPaths, scheme)
+}
+
+// usesScheme 判断 outputPaths 中是否使用了 scheme
+func usesScheme(outputPaths []string, scheme string) bool {
+	if scheme == "" {
+		return false
+	}
+	for _, p := range outputPaths {
+		if strings.HasPrefix(p, scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) samplingOption() *SamplingOption {
+	if c.Sampling == nil {
+		return nil
+	}
+	tick, _ := time.ParseDuration(c.Sampling.Tick)
+	return &SamplingOption{
+		Disable:              c.Sampling.Disable,
+		Tick:                 tick,
+		Initial:              c.Sampling.Initial,
+		Thereafter:           c.Sampling.Thereafter,
+		Levels:               c.Sampling.Levels,
+		DisableErrorAndAbove: c.Sampling.DisableErrorAndAbove,
+	}
+}
+
+func (c *Config) asyncOption() *AsyncOption {
+	if c.Async == nil {
+		return nil
+	}
+	interval, _ := time.ParseDuration(c.Async.FlushInterval)
+	return &AsyncOption{
+		BufferSize:    c.Async.BufferSize,
+		FlushInterval: interval,
+		FlushSize:     c.Async.FlushSize,
+		DropWhenFull:  c.Async.DropWhenFull,
+	}
+}
+
+func (c *Config) syncPolicy() *SyncPolicy {
+	if c.SyncPolicy == nil {
+		return nil
+	}
+	interval, _ := time.ParseDuration(c.SyncPolicy.Interval)
+	return &SyncPolicy{Interval: interval, Level: c.SyncPolicy.Level}
+}
+
+// Options 将配置转换为 NewLogger 使用的 Options ，配置了 sentry dsn 时会创建 sentry client
+func (c *Config) Options() (Options, error) {
+	if err := c.Validate(); err != nil {
+		return Options{}, err
+	}
+	options := Options{
+		Name:              c.Name,
+		Level:             c.Level,
+		Format:            c.Format,
+		OutputPaths:       c.OutputPaths,
+		InitialFields:     c.InitialFields,
+		DisableCaller:     c.DisableCaller,
+		DisableStacktrace: c.DisableStacktrace,
+		Sampling:          c.samplingOption(),
+		SentryTags:        c.SentryTags,
+		Async:             c.asyncOption(),
+		SyncPolicy:        c.syncPolicy(),
+	}
+	for _, r := range c.Routes {
+		route := Route{
+			LoggerNames: r.LoggerNames,
+			Level:       r.Level,
+			Format:      r.Format,
+			OutputPaths: r.OutputPaths,
+			Exclusive:   r.Exclusive,
+		}
+		if lj := r.Lumberjack; lj != nil {
+			route.LumberjackSink = NewLumberjackSink(lj.Scheme, lj.Filename, lj.MaxAge, lj.MaxBackups, lj.MaxSize, lj.Compress, lj.LocalTime)
+		}
+		options.Routes = append(options.Routes, route)
+	}
+	if c.AtomicLevelServer != nil {
+		options.AtomicLevelServer = *c.AtomicLevelServer
+	}
+	encoderConfig := EncoderConfig
+	if c.EncoderConfig != nil {
+		encoderConfig = c.EncoderConfig.encoderConfig(EncoderConfig)
+		options.EncoderConfig = &encoderConfig
+	}
+	for _, o := range c.Outputs {
+		options.Outputs = append(options.Outputs, o.output(encoderConfig))
+	}
+	if lj := c.Lumberjack; lj != nil {
+		options.LumberjackSink = NewLumberjackSink(lj.Scheme, lj.Filename, lj.MaxAge, lj.MaxBackups, lj.MaxSize, lj.Compress, lj.LocalTime)
+	}
+	if c.Redact != nil {
+		redactor, err := NewRedactor(*c.Redact)
+		if err != nil {
+			return Options{}, err
+		}
+		options.Redactor = redactor
+	}
+	if c.SentryDSN != "" {
+		client, err := NewSentryClient(sentry.ClientOptions{Dsn: c.SentryDSN, Debug: c.SentryDebug})
+		if err != nil {
+			return Options{}, fmt.Errorf("new sentry client error: %w", err)
+		}
+		options.SentryClient = client
+	}
+	return options, nil
+}
+
+// output 转换为 Output ，设置了 encoder_config 时在顶层 encoder_config 对应的 base 的基础上设置
+func (o OutputConfig) output(base zapcore.EncoderConfig) Output {
+	output := Output{Path: o.Path, Format: o.Format, Color: o.Color}
+	if o.EncoderConfig != nil {
+		encoderConfig := o.EncoderConfig.encoderConfig(base)
+		output.EncoderConfig = &encoderConfig
+	}
+	return output
+}
+
+// validate 校验编码方式配置， name 为错误信息中的配置项名称
+func (ec *EncoderKeysConfig) validate(name string) []error {
+	var errs []error
+	if _, exists := configLevelEncoders[ec.LevelEncoder]; ec.LevelEncoder != "" && !exists {
+		errs = append(errs, fmt.Errorf("invalid %s.level_encoder: %s", name, ec.LevelEncoder))
+	}
+	if _, exists := configTimeEncoders[ec.TimeEncoder]; ec.TimeEncoder != "" && !exists {
+		errs = append(errs, fmt.Errorf("invalid %s.time_encoder: %s", name, ec.TimeEncoder))
+	}
+	if _, exists := configDurationEncoders[ec.DurationEncoder]; ec.DurationEncoder != "" && !exists {
+		errs = append(errs, fmt.Errorf("invalid %s.duration_encoder: %s", name, ec.DurationEncoder))
+	}
+	return errs
+}
+
+// encoderConfig 在 base 的基础上设置配置的值
+func (ec *EncoderKeysConfig) encoderConfig(base zapcore.EncoderConfig) zapcore.EncoderConfig {
+	cfg := base
+	setIfNotEmpty := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	setIfNotEmpty(&cfg.TimeKey, ec.TimeKey)
+	setIfNotEmpty(&cfg.LevelKey, ec.LevelKey)
+	setIfNotEmpty(&cfg.NameKey, ec.NameKey)
+	setIfNotEmpty(&cfg.CallerKey, ec.CallerKey)
+	setIfNotEmpty(&cfg.FunctionKey, ec.FunctionKey)
+	setIfNotEmpty(&cfg.MessageKey, ec.MessageKey)
+	setIfNotEmpty(&cfg.StacktraceKey, ec.StacktraceKey)
+	if encoder, exists := configLevelEncoders[ec.LevelEncoder]; exists {
+		cfg.EncodeLevel = encoder
+	}
+	if encoder, exists := configTimeEncoders[ec.TimeEncoder]; exists {
+		cfg.EncodeTime = encoder
+	}
+	if encoder, exists := configDurationEncoders[ec.DurationEncoder]; exists {
+		cfg.EncodeDuration = encoder
+	}
+	return cfg
+}
+
+// loadEnv 根据 json tag 从环境变量中设置 struct 的字段值，嵌套 struct 的环境变量名以 _ 连接
+func loadEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		envKey := prefix + "_" + strings.ToUpper(name)
+		fv := v.Field(i)
+
+		// 嵌套 struct 指针，有对应前缀的环境变量时才创建
+		if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+			if !hasEnvPrefix(envKey + "_") {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			if err := loadEnv(fv.Elem(), envKey); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, exists := os.LookupEnv(envKey)
+		if !exists {
+			continue
+		}
+		if err := setEnvValue(fv, value); err != nil {
+			return fmt.Errorf("invalid env %s=%s: %w", envKey, value, err)
+		}
+	}
+	return nil
+}
+
+// setEnvValue 将环境变量的值转换后设置到字段中
+func setEnvValue(fv reflect.Value, value string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return errors.New("unsupported env slice type " + fv.Type().String())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		fv.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		elemKind := fv.Type().Elem().Kind()
+		if fv.Type().Key().Kind() != reflect.String || (elemKind != reflect.Interface && elemKind != reflect.String) {
+			return errors.New("unsupported env map type " + fv.Type().String())
+		}
+		m := reflect.MakeMap(fv.Type())
+		for _, kv := range strings.Split(value, ",") {
+			if kv = strings.TrimSpace(kv); kv == "" {
+				continue
+			}
+			k, v, found := strings.Cut(kv, "=")
+			if !found {
+				return errors.New("map value must be k1=v1,k2=v2")
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)), reflect.ValueOf(strings.TrimSpace(v)).Convert(fv.Type().Elem()))
+		}
+		fv.Set(m)
+	default:
+		return errors.New("unsupported env type " + fv.Type().String())
+	}
+	return nil
+}
+
+// hasEnvPrefix 是否存在指定前缀的环境变量
+func hasEnvPrefix(prefix string) bool {
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFiles(t *testing.T) {
+	files := map[string]string{
+		"logging.yaml": `
+name: demo
+level: warn
+format: console
+output_paths: [stdout]
+initial_fields:
+  service: demo
+encoder_config:
+  message_key: message
+  time_encoder: rfc3339
+sampling:
+  tick: 2s
+  initial: 10
+  levels:
+    error:
+      disable: true
+`,
+		"logging.json": `{
+	"name": "demo",
+	"level": "warn",
+	"format": "console",
+	"output_paths": ["stdout"],
+	"initial_fields": {"service": "demo"},
+	"encoder_config": {"message_key": "message", "time_encoder": "rfc3339"},
+	"sampling": {"tick": "2s", "initial": 10, "levels": {"error": {"disable": true}}}
+}`,
+		"logging.toml": `
+name = "demo"
+level = "warn"
+format = "console"
+output_paths = ["stdout"]
+
+[initial_fields]
+service = "demo"
+
+[encoder_config]
+message_key = "message"
+time_encoder = "rfc3339"
+
+[sampling]
+tick = "2s"
+initial = 10
+
+[sampling.levels.error]
+disable = true
+`,
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			options, err := LoadOptions(writeConfigFile(t, name, content), "LOGGING_TEST")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if options.Name != "demo" || options.Level != "warn" || options.Format != "console" {
+				t.Fatalf("invalid options: %+v", options)
+			}
+			if len(options.OutputPaths) != 1 || options.OutputPaths[0] != "stdout" {
+				t.Fatal("invalid output paths", options.OutputPaths)
+			}
+			if options.InitialFields["service"] != "demo" {
+				t.Fatal("invalid initial fields", options.InitialFields)
+			}
+			if options.EncoderConfig == nil || options.EncoderConfig.MessageKey != "message" || options.EncoderConfig.LevelKey != EncoderConfig.LevelKey {
+				t.Fatal("invalid encoder config", options.EncoderConfig)
+			}
+			if options.Sampling == nil || options.Sampling.Tick != 2*time.Second || options.Sampling.Initial != 10 || !options.Sampling.Levels["error"].Disable {
+				t.Fatal("invalid sampling", options.Sampling)
+			}
+		})
+	}
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	path := writeConfigFile(t, "logging.yaml", "level: warn\nname: file\n")
+	t.Setenv("LOGGING_TEST_LEVEL", "error")
+	t.Setenv("LOGGING_TEST_OUTPUT_PATHS", "stdout, lumberjack-test://localhost")
+	t.Setenv("LOGGING_TEST_INITIAL_FIELDS", "service=demo,env=test")
+	t.Setenv("LOGGING_TEST_DISABLE_CALLER", "true")
+	t.Setenv("LOGGING_TEST_LUMBERJACK_SCHEME", "lumberjack-test")
+	t.Setenv("LOGGING_TEST_LUMBERJACK_MAX_SIZE", "10")
+	t.Setenv("LOGGING_TEST_ATOMIC_LEVEL_SERVER_ADDR", ":1903")
+	t.Setenv("LOGGING_TEST_REDACT_FIELDS", "password,token")
+	t.Setenv("LOGGING_TEST_ASYNC_FLUSH_INTERVAL", "200ms")
+	t.Setenv("LOGGING_TEST_ASYNC_DROP_WHEN_FULL", "true")
+
+	options, err := LoadOptions(path, "LOGGING_TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.Name != "file" || options.Level != "error" || !options.DisableCaller {
+		t.Fatalf("env should override file: %+v", options)
+	}
+	if len(options.OutputPaths) != 2 || options.OutputPaths[1] != "lumberjack-test://localhost" {
+		t.Fatal("invalid output paths", options.OutputPaths)
+	}
+	if options.InitialFields["service"] != "demo" || options.InitialFields["env"] != "test" {
+		t.Fatal("invalid initial fields", options.InitialFields)
+	}
+	if options.LumberjackSink == nil || options.LumberjackSink.MaxSize != 10 {
+		t.Fatal("invalid lumberjack sink", options.LumberjackSink)
+	}
+	if options.AtomicLevelServer.Addr != ":1903" {
+		t.Fatal("invalid atomic level server", options.AtomicLevelServer)
+	}
+	if options.Redactor == nil || options.Redactor.Redact("token", "x") != RedactMaskString {
+		t.Fatal("invalid redactor")
+	}
+	if options.Async == nil || options.Async.FlushInterval != 200*time.Millisecond || !options.Async.DropWhenFull {
+		t.Fatal("invalid async option", options.Async)
+	}
+}
+
+func TestLoadConfigValidate(t *testing.T) {
+	t.Setenv("LOGGING_TEST_LEVEL", "verbose")
+	t.Setenv("LOGGING_TEST_FORMAT", "xml")
+	t.Setenv("LOGGING_TEST_SAMPLING_TICK", "1x")
+	if _, err := LoadConfig("", "LOGGING_TEST"); err == nil {
+		t.Fatal("invalid config should return error")
+	}
+	t.Setenv("LOGGING_TEST_DISABLE_CALLER", "yes?")
+	if _, err := LoadConfig("", "LOGGING_TEST"); err == nil {
+		t.Fatal("invalid env should return error")
+	}
+	if _, err := LoadConfig(writeConfigFile(t, "logging.ini", ""), "LOGGING_TEST"); err == nil {
+		t.Fatal("unsupported file type should return error")
+	}
+}
+
+func TestLoadConfigRoutes(t *testing.T) {
+	path := writeConfigFile(t, "logging.yaml", `
+output_paths: [stdout]
+routes:
+  - level: error
+    format: console
+    output_paths: [stderr, lumberjack-error://localhost]
+    lumberjack:
+      scheme: lumberjack-error
+      filename: /tmp/error.log
+  - logger_names: [logging.gin.access_logger.*]
+    output_paths: [/tmp/access.log]
+    exclusive: true
+`)
+	options, err := LoadOptions(path, "LOGGING_TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options.Routes) != 2 || options.Routes[0].LumberjackSink == nil || options.Routes[0].LumberjackSink.Filename != "/tmp/error.log" {
+		t.Fatalf("invalid routes: %+v", options.Routes)
+	}
+	if !options.Routes[1].Exclusive || options.Routes[1].LoggerNames[0] != "logging.gin.access_logger.*" {
+		t.Fatalf("invalid routes: %+v", options.Routes[1])
+	}
+
+	invalid := writeConfigFile(t, "logging.yaml", "routes:\n  - level: x\n    format: xml\n")
+	if _, err := LoadConfig(invalid, "LOGGING_TEST"); err == nil {
+		t.Fatal("invalid route should return error")
+	}
+}
+
+func TestLoadConfigOutputs(t *testing.T) {
+	path := writeConfigFile(t, "logging.yaml", `
+encoder_config:
+  message_key: message
+  level_encoder: capital
+outputs:
+  - path: stdout
+    format: console
+    color: auto
+    encoder_config:
+      level_encoder: lowercase
+  - path: lumberjack://localhost
+    format: json
+lumberjack:
+  scheme: lumberjack
+  filename: /tmp/outputs.log
+`)
+	options, err := LoadOptions(path, "LOGGING_TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options.Outputs) != 2 || options.Outputs[0].EncoderConfig == nil || options.Outputs[1].EncoderConfig != nil || options.LumberjackSink == nil {
+		t.Fatalf("invalid outputs: %+v", options.Outputs)
+	}
+	// 日志输出的 encoder_config 在顶层 encoder_config 的基础上设置
+	if ec := options.Outputs[0].EncoderConfig; ec.MessageKey != "message" || options.EncoderConfig.MessageKey != "message" {
+		t.Fatalf("output encoder config should be based on encoder_config: %+v", ec)
+	}
+
+	invalid := writeConfigFile(t, "logging.yaml", "outputs:\n  - path: stdout\n    color: x\n    encoder_config:\n      level_encoder: x\n")
+	if _, err := LoadConfig(invalid, "LOGGING_TEST"); err == nil {
+		t.Fatal("invalid output should return error")
+	}
+}
@@ -5,10 +5,11 @@ package logging
 import (
 	"context"
 	"log"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 	jsoniter "github.com/json-iterator/go"
-	"github.com/rs/xid"
 	"go.uber.org/zap"
 )
 
@@ -22,64 +23,212 @@ var (
 	TraceIDKeyname Ctxkey = "trace_id"
 	// TraceIDPrefix set the prefix when gen a trace id
 	TraceIDPrefix = "logging_"
+	// TraceIDFromBody 是否从 gin 请求 body 的 JSON 中获取 trace id ，需要读取并解析整个 body ，默认关闭
+	TraceIDFromBody = false
+	// TraceIDForEmptyContext 是否为 nil 、 context.Background() 等没有 trace id 的 context 打印的每条日志生成新的 trace id
+	// 每次调用都会生成 trace id 并创建 logger ，默认关闭，关闭时这些日志不带 trace id
+	TraceIDForEmptyContext = false
 )
 
 // CtxLogger get the ctxLogger in context
+// context 中的 ctx logger 只会创建一次，没有 trace id 的 context 返回全局的 ctx logger
 func CtxLogger(c context.Context, fields ...zap.Field) *zap.Logger {
 	if c == nil {
 		c = context.Background()
 	}
-	var ctxLoggerItf interface{}
+	var ctxLogger *zap.Logger
+	if gc, ok := c.(*gin.Context); ok && len(fields) > 0 {
+		// 传入了字段时不添加 ClientIP 和 RequestURI
+		ctxLogger = ginCtxStoredLogger(gc)
+	} else if h, ctxFields := resolveCtxHelper(c); h != nil {
+		ctxLogger = h.ctxLogger
+	} else {
+		ctxLogger = rootHelper.Load().ctxLogger.With(ctxFields...)
+	}
+
+	if len(fields) > 0 {
+		ctxLogger = ctxLogger.With(fields...)
+	}
+	return ctxLogger
+}
+
+// ginCtxStoredLogger 获取 gin context 中保存的 ctx logger ，没有时创建并保存
+func ginCtxStoredLogger(gc *gin.Context) *zap.Logger {
+	if ctxLogger, ok := ctxStoredLogger(gc); ok {
+		return ctxLogger
+	}
+	_, ctxLogger := NewCtxLogger(gc, CloneLogger(string(CtxLoggerName)), CtxTraceID(gc))
+	return ctxLogger
+}
+
+// ctxHelperKey 在 context 中保存全局方法使用的 logger 的 key
+const ctxHelperKey Ctxkey = "ctx_helper_logger"
+
+// rootHelper 没有 ctx logger 的 context 使用的全局方法 logger ，随 ReplaceLogger 更新
+var rootHelper atomic.Pointer[ctxHelper]
+
+// ctxHelper context 对应的 ctx logger 和全局方法使用的 logger ，创建后缓存在 context 中
+// 全局方法使用的 logger 在 ctx logger 的基础上增加了 caller skip ，第一次使用时创建
+type ctxHelper struct {
+	// base context 中保存的 ctx logger ，用于判断缓存是否有效
+	base *zap.Logger
+	// ctxLogger CtxLogger 返回的 logger
+	ctxLogger *zap.Logger
+	once      sync.Once
+	logger    *zap.Logger
+	sugar     *zap.SugaredLogger
+}
+
+func (h *ctxHelper) init() *ctxHelper {
+	h.once.Do(func() {
+		h.logger = h.ctxLogger.WithOptions(zap.AddCallerSkip(1))
+		h.sugar = h.logger.Sugar()
+	})
+	return h
+}
+
+// setRootHelper 使用新的全局 logger 更新 rootHelper
+func setRootHelper(l *zap.Logger) {
+	ctxLogger := l.Named(string(CtxLoggerName))
+	rootHelper.Store((&ctxHelper{base: ctxLogger, ctxLogger: ctxLogger}).init())
+}
+
+// resolveCtxHelper 返回 context 对应的 ctx logger 和全局方法 logger
+// context 中没有 ctx logger 但有 trace id 时返回 nil 和需要添加到 rootHelper 上的字段
+func resolveCtxHelper(c context.Context) (*ctxHelper, []zap.Field) {
+	// 空的 context 中没有任何值，不需要查找
+	if c == nil || c == context.Background() || c == context.TODO() {
+		if TraceIDForEmptyContext {
+			return nil, []zap.Field{zap.String(string(TraceIDKeyname), newTraceID())}
+		}
+		return rootHelper.Load(), nil
+	}
 	if gc, ok := c.(*gin.Context); ok {
-		ctxLoggerItf, _ = gc.Get(string(CtxLoggerName))
-		if len(fields) == 0 && gc.Request != nil {
-			fields = append(fields,
+		// gin context 的 ctx logger 添加 ClientIP 和 RequestURI 字段后缓存在 gin context 中
+		stored, exists := ctxStoredLogger(gc)
+		if cached, ok := gc.Get(string(ctxHelperKey)); ok && exists {
+			if h, ok := cached.(*ctxHelper); ok && h.base == stored {
+				return h, nil
+			}
+		}
+		stored = ginCtxStoredLogger(gc)
+		ctxLogger := stored
+		if gc.Request != nil {
+			ctxLogger = stored.With(
 				zap.String("ClientIP", SafeClientIP(gc)),
 				zap.String("RequestURI", gc.Request.RequestURI),
 			)
 		}
-	} else {
-		ctxLoggerItf = c.Value(CtxLoggerName)
+		h := &ctxHelper{base: stored, ctxLogger: ctxLogger}
+		gc.Set(string(ctxHelperKey), h)
+		return h, nil
+	}
+	if stored, ok := ctxStoredLogger(c); ok {
+		if h, ok := c.Value(ctxHelperKey).(*ctxHelper); ok && h.base == stored {
+			return h, nil
+		}
+		return &ctxHelper{base: stored, ctxLogger: stored}, nil
+	}
+	// 没有 trace id 的 context 直接使用全局的 ctx logger ，不带 trace id ，开启 TraceIDForEmptyContext 时为每次调用生成 trace id
+	traceID := lookupTraceID(c)
+	if traceID == "" && !CtxForceDebug(c) && !TraceIDForEmptyContext {
+		return rootHelper.Load(), nil
+	}
+	if traceID == "" {
+		traceID = newTraceID()
 	}
+	fields, _, _, _ := ctxLoggerFields(c, traceID)
+	return nil, fields
+}
 
-	var ctxLogger *zap.Logger
-	if ctxLoggerItf != nil {
-		ctxLogger = ctxLoggerItf.(*zap.Logger)
+// 查找 context 中的值时使用的 key
+// Ctxkey 变量每次转换为 interface{} 时都会产生内存分配，未修改 CtxLoggerName 和 TraceIDKeyname 时使用预先转换的 key
+var (
+	ctxLoggerKey  interface{} = CtxLoggerName
+	ctxTraceIDKey interface{} = TraceIDKeyname
+)
+
+// ctxValueKey 返回 key 对应的 context key ，与预先转换的 key 相同时直接返回 converted
+func ctxValueKey(converted interface{}, key Ctxkey) interface{} {
+	if converted.(Ctxkey) == key {
+		return converted
+	}
+	return key
+}
+
+// ctxStoredLogger 获取 context 中已经保存的 ctx logger ，不会创建新的 logger
+func ctxStoredLogger(c context.Context) (*zap.Logger, bool) {
+	var ctxLoggerItf interface{}
+	if gc, ok := c.(*gin.Context); ok {
+		ctxLoggerItf, _ = gc.Get(string(CtxLoggerName))
 	} else {
-		_, ctxLogger = NewCtxLogger(c, CloneLogger(string(CtxLoggerName)), CtxTraceID(c))
+		ctxLoggerItf = c.Value(ctxValueKey(ctxLoggerKey, CtxLoggerName))
 	}
+	ctxLogger, ok := ctxLoggerItf.(*zap.Logger)
+	return ctxLogger, ok
+}
 
-	if len(fields) > 0 {
-		ctxLogger = ctxLogger.With(fields...)
+// ctxStoredTraceID 获取 context 中已经保存的 trace id ，不会生成新的 trace id
+func ctxStoredTraceID(c context.Context) string {
+	if gc, ok := c.(*gin.Context); ok {
+		if traceID := gc.GetString(string(TraceIDKeyname)); traceID != "" {
+			return traceID
+		}
 	}
-	return ctxLogger
+	traceID, _ := c.Value(TraceIDKeyname).(string)
+	return traceID
 }
 
 // CtxTraceID get trace id from context
-// Modify TraceIDPrefix change change the prefix
+// context 中没有 trace id 时使用全局的 trace id 生成器生成，通过 SetTraceIDGenerator 修改生成器
 func CtxTraceID(c context.Context) string {
 	if c == nil {
 		c = context.Background()
 	}
+	if traceID := lookupTraceID(c); traceID != "" {
+		return traceID
+	}
+	// return default value
+	return newTraceID()
+}
+
+// lookupTraceID 从 context 中获取 trace id ，没有时返回空字符串
+func lookupTraceID(c context.Context) string {
 	// first get from gin context
 	if gc, ok := c.(*gin.Context); ok {
 		if traceID := gc.GetString(string(TraceIDKeyname)); traceID != "" {
 			return traceID
 		}
-		if traceID := gc.Query(string(TraceIDKeyname)); traceID != "" {
+		// traceparent 、 query string 和 body 中的 trace id 由客户端传入，与 gin 中间件一样使用全局的 TraceIDValidator 校验
+		validator := GetTraceIDValidator()
+		// get from OpenTelemetry span or W3C traceparent header
+		if traceID, _, _ := ctxW3CSpan(gc); validTraceID(traceID, validator) {
 			return traceID
 		}
-		if traceID := jsoniter.Get(GetGinRequestBody(gc), string(TraceIDKeyname)).ToString(); traceID != "" {
+		if traceID := gc.Query(string(TraceIDKeyname)); validTraceID(traceID, validator) {
 			return traceID
 		}
+		// 需要读取并解析整个 body ，开启 TraceIDFromBody 后才从 body 中获取
+		if TraceIDFromBody {
+			if traceID := jsoniter.Get(GetGinRequestBody(gc), string(TraceIDKeyname)).ToString(); validTraceID(traceID, validator) {
+				return traceID
+			}
+		}
 	}
 	// get from go context
-	traceIDItf := c.Value(TraceIDKeyname)
-	if traceIDItf != nil {
-		return traceIDItf.(string)
+	if traceID, ok := c.Value(ctxValueKey(ctxTraceIDKey, TraceIDKeyname)).(string); ok {
+		return traceID
 	}
-	// return default value
-	return TraceIDPrefix + xid.New().String()
+	// get from OpenTelemetry span
+	if traceID, _, _ := ctxW3CSpan(c); traceID != "" {
+		return traceID
+	}
+	return ""
+}
+
+// newTraceID 使用全局的 trace id 生成器生成新的 trace id
+func newTraceID() string {
+	return GetTraceIDGenerator().NewTraceID()
 }
 
 // NewCtxLogger return a context with logger and trace id and a logger with trace id
@@ -90,18 +239,110 @@ func NewCtxLogger(c context.Context, logger *zap.Logger, traceID string) (contex
 	if traceID == "" {
 		traceID = CtxTraceID(c)
 	}
-	ctxLogger := logger.With(zap.String(string(TraceIDKeyname), traceID))
+	fields, spanID, parentSpanID, forceDebug := ctxLoggerFields(c, traceID)
+	ctxLogger := logger.With(fields...)
 	if gc, ok := c.(*gin.Context); ok {
 		// set ctxlogger in gin.Context
 		gc.Set(string(CtxLoggerName), ctxLogger)
 		// set traceID in gin.Context
 		gc.Set(string(TraceIDKeyname), traceID)
+		// set spanID in gin.Context
+		if spanID != "" {
+			gc.Set(string(SpanIDKeyname), spanID)
+		}
+		if parentSpanID != "" {
+			gc.Set(string(ParentSpanIDKeyname), parentSpanID)
+		}
+		if forceDebug {
+			gc.Set(string(ForceDebugKeyname), true)
+		}
+	}
+	return withCtxValues(c, ctxLogger, traceID, spanID, parentSpanID, forceDebug), ctxLogger
+}
+
+// WithFields 在 context 中保存的 ctx logger 上添加字段，之后使用该 context 的 CtxLogger 、全局方法和 GormLogger 打印的日志都会带有这些字段
+// 多次调用时字段会累加。 gin.Context 会直接修改其中保存的 ctx logger 并返回它本身，
+// 如果 request context 中也保存了 ctx logger （使用了 GinLogger 中间件），会同时添加到 c.Request.Context() 中
+// ctx = logging.WithFields(ctx, zap.String("user_id", uid), zap.String("tenant_id", tid))
+func WithFields(c context.Context, fields ...zap.Field) context.Context {
+	if c == nil {
+		c = context.Background()
 	}
+	if len(fields) == 0 {
+		return c
+	}
+	if gc, ok := c.(*gin.Context); ok {
+		// 保存的 ctx logger 变化后缓存的全局方法 logger 会失效并重新创建
+		gc.Set(string(CtxLoggerName), ginCtxStoredLogger(gc).With(fields...))
+		if gc.Request != nil {
+			if reqLogger, ok := ctxStoredLogger(gc.Request.Context()); ok {
+				gc.Request = gc.Request.WithContext(withCtxLogger(gc.Request.Context(), reqLogger.With(fields...)))
+			}
+		}
+		return gc
+	}
+	ctxLogger, ok := ctxStoredLogger(c)
+	if !ok {
+		// 没有 ctx logger 时创建并同时保存 trace id ，之后 CtxTraceID 返回与日志中相同的 trace id
+		c, ctxLogger = NewCtxLogger(c, CloneLogger(string(CtxLoggerName)), "")
+	}
+	return withCtxLogger(c, ctxLogger.With(fields...))
+}
+
+// withCtxValues 在 context.Context 中保存 ctx logger 、 trace id 、 span id 、 parent span id 和是否开启 debug 日志
+func withCtxValues(c context.Context, ctxLogger *zap.Logger, traceID, spanID, parentSpanID string, forceDebug bool) context.Context {
 	// set ctxlogger in context.Context
-	c = context.WithValue(c, CtxLoggerName, ctxLogger)
+	c = withCtxLogger(c, ctxLogger)
 	// set traceID in context.Context
 	c = context.WithValue(c, TraceIDKeyname, traceID)
-	return c, ctxLogger
+	// set spanID in context.Context
+	if spanID != "" {
+		c = context.WithValue(c, SpanIDKeyname, spanID)
+	}
+	if parentSpanID != "" {
+		c = context.WithValue(c, ParentSpanIDKeyname, parentSpanID)
+	}
+	if forceDebug {
+		c = context.WithValue(c, ForceDebugKeyname, true)
+	}
+	return c
+}
+
+// ctxLoggerFields 返回 ctx logger 需要添加的 trace id 、 span id 、 parent span id 和开启 debug 日志的字段
+func ctxLoggerFields(c context.Context, traceID string) (fields []zap.Field, spanID, parentSpanID string, forceDebug bool) {
+	// span id 只在属于同一个 trace 时才记录
+	if ctxStoredTraceID(c) == traceID {
+		spanID = ctxStoredSpanID(c)
+		parentSpanID = ctxStoredParentSpanID(c)
+	}
+	if spanID == "" {
+		if w3cTraceID, w3cSpanID, _ := ctxW3CSpan(c); w3cTraceID == traceID {
+			spanID = w3cSpanID
+		}
+	}
+	fields = []zap.Field{zap.String(string(TraceIDKeyname), traceID)}
+	if spanID != "" {
+		fields = append(fields, zap.String(string(SpanIDKeyname), spanID))
+	}
+	if parentSpanID != "" {
+		fields = append(fields, zap.String(string(ParentSpanIDKeyname), parentSpanID))
+	}
+	// ExtractTraceContext 丢弃的不合法的 trace id
+	if invalidTraceID, ok := c.Value(InvalidTraceIDKeyname).(string); ok {
+		fields = append(fields, zap.String(string(InvalidTraceIDKeyname), invalidTraceID))
+	}
+	// 请求开启了 debug 日志或 trace id 已注册时 ctx logger 打印 debug 日志
+	forceDebug = CtxForceDebug(c) || IsForceDebugTraceID(traceID)
+	if forceDebug {
+		fields = append(fields, forceDebugField())
+	}
+	return fields, spanID, parentSpanID, forceDebug
+}
+
+// withCtxLogger 在 context.Context 中保存 ctx logger 和全局方法使用的 logger
+func withCtxLogger(c context.Context, ctxLogger *zap.Logger) context.Context {
+	c = context.WithValue(c, CtxLoggerName, ctxLogger)
+	return context.WithValue(c, ctxHelperKey, &ctxHelper{base: ctxLogger, ctxLogger: ctxLogger})
 }
 
 // SafeClientIP 安全地获取客户端 IP，避免 nil engine 导致的 panic
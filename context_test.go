@@ -5,9 +5,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestGinContext(t *testing.T) {
@@ -54,6 +57,37 @@ func TestCtxLoggerEmpty(t *testing.T) {
 	logger.Info("this is a logger from empty ctx")
 }
 
+func TestCtxLoggerEmptyTraceID(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+	Info(nil, "nil")
+	Info(context.Background(), "background")
+	CtxLogger(context.TODO()).Info("todo")
+	for _, entry := range logs.TakeAll() {
+		if _, exists := entry.ContextMap()[string(TraceIDKeyname)]; exists {
+			t.Fatal("empty context should not have trace id by default", entry.Message, entry.ContextMap())
+		}
+	}
+
+	// 开启 TraceIDForEmptyContext 后每次调用生成新的 trace id
+	defer func() { TraceIDForEmptyContext = false }()
+	TraceIDForEmptyContext = true
+	Info(nil, "nil")
+	Infow(context.Background(), "background")
+	CtxLogger(context.TODO()).Info("todo")
+	traceIDs := map[interface{}]struct{}{}
+	for _, entry := range logs.TakeAll() {
+		traceID := entry.ContextMap()[string(TraceIDKeyname)]
+		if traceID == nil || traceID == "" {
+			t.Fatal("empty context should have trace id", entry.Message, entry.ContextMap())
+		}
+		traceIDs[traceID] = struct{}{}
+	}
+	if len(traceIDs) != 3 {
+		t.Fatal("each call should generate a new trace id", traceIDs)
+	}
+}
+
 func TestCtxLoggerEmptyField(t *testing.T) {
 	c := context.Background()
 
@@ -119,6 +153,22 @@ func TestGinCtxTraceID(t *testing.T) {
 	}
 }
 
+func TestGinCtxTraceIDValidator(t *testing.T) {
+	defer SetTraceIDValidator(GetTraceIDValidator())
+	SetTraceIDValidator(IsUUID)
+	gin.SetMode(gin.ReleaseMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/?trace_id=not-a-uuid", nil)
+	if traceID := lookupTraceID(c); traceID != "" {
+		t.Fatal("trace id rejected by the validator should be ignored", traceID)
+	}
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/?trace_id=0191b0b4-5a8e-7c3e-9f1a-2b3c4d5e6f70", nil)
+	if traceID := lookupTraceID(c); traceID != "0191b0b4-5a8e-7c3e-9f1a-2b3c4d5e6f70" {
+		t.Fatal("valid trace id should be returned", traceID)
+	}
+}
+
 func TestCtxTraceID(t *testing.T) {
 	c := context.Background()
 	if CtxTraceID(c) == "" {
@@ -129,3 +179,83 @@ func TestCtxTraceID(t *testing.T) {
 		t.Fatal("context should return set value")
 	}
 }
+
+func TestCtxTraceIDKeynameChanged(t *testing.T) {
+	defer func(keyname Ctxkey) { TraceIDKeyname = keyname }(TraceIDKeyname)
+	TraceIDKeyname = "request_id"
+	c := context.WithValue(context.Background(), TraceIDKeyname, "IAMAREQUESTID")
+	if CtxTraceID(c) != "IAMAREQUESTID" {
+		t.Fatal("context should return value of the changed keyname")
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+	gormLogger := NewGormLogger(zapcore.InfoLevel, zap.DebugLevel, 5*time.Second)
+
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("test"), "with-fields-trace-id")
+	userCtx := WithFields(ctx, zap.String("user_id", "u1"))
+	orderCtx := WithFields(userCtx, zap.String("tenant_id", "t1"), zap.String("order_id", "o1"))
+	Info(orderCtx, "info")
+	Errorw(orderCtx, "errorw", "k", "v")
+	gormLogger.Info(orderCtx, "gorm")
+	Info(userCtx, "user")
+	nilCtx := WithFields(nil, zap.String("user_id", "u2"))
+	Info(nilCtx, "nil")
+
+	entries := logs.AllUntimed()
+	if len(entries) != 5 {
+		t.Fatal("invalid log count", len(entries))
+	}
+	for _, entry := range entries[:3] {
+		fields := entry.ContextMap()
+		if fields["user_id"] != "u1" || fields["tenant_id"] != "t1" || fields["order_id"] != "o1" || fields[string(TraceIDKeyname)] != "with-fields-trace-id" {
+			t.Fatal("fields should be accumulated", entry.Message, fields)
+		}
+	}
+	if fields := entries[3].ContextMap(); fields["user_id"] != "u1" || fields["tenant_id"] != nil {
+		t.Fatal("parent context should not have child fields", fields)
+	}
+	// 没有 ctx logger 的 context 使用新的 trace id ，与 CtxTraceID 返回的相同
+	if fields := entries[4].ContextMap(); fields["user_id"] != "u2" || fields[string(TraceIDKeyname)] != CtxTraceID(nilCtx) {
+		t.Fatal("nil context should have the added fields and its trace id", fields, CtxTraceID(nilCtx))
+	}
+}
+
+func TestWithFieldsGinContext(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	app.Use(GinLogger())
+	app.GET("/fields", func(c *gin.Context) {
+		Info(c, "before")
+		if WithFields(c, zap.String("user_id", "u1")) != c {
+			t.Error("gin context should be returned")
+		}
+		WithFields(c, zap.String("tenant_id", "t1"))
+		Infow(c, "gin")
+		CtxLogger(c.Request.Context()).Info("request")
+	})
+	req := httptest.NewRequest(http.MethodGet, "/fields", nil)
+	req.Header.Set(string(TraceIDKeyname), "gin-fields-trace-id")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	if fields := logs.FilterMessage("before").AllUntimed()[0].ContextMap(); fields["user_id"] != nil {
+		t.Fatal("fields should not be added before WithFields", fields)
+	}
+	for _, msg := range []string{"gin", "request"} {
+		entries := logs.FilterMessage(msg).AllUntimed()
+		if len(entries) != 1 {
+			t.Fatal("invalid log count", msg, len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if fields["user_id"] != "u1" || fields["tenant_id"] != "t1" || fields[string(TraceIDKeyname)] != "gin-fields-trace-id" {
+			t.Fatal("gin context fields should be accumulated", msg, fields)
+		}
+	}
+	if fields := logs.FilterMessage("gin").AllUntimed()[0].ContextMap(); fields["RequestURI"] != "/fields" {
+		t.Fatal("gin helper should keep request fields", fields)
+	}
+}
@@ -0,0 +1,231 @@
+// 按请求开启 debug 日志
+// 请求携带签名的 ForceDebugHeader 或 trace id 通过 AtomicLevel server 注册后，
+// 该请求的 ctx logger 不受全局日志级别和按名称设置的日志级别限制，会打印 debug 日志
+
+package logging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// ForceDebugHeader 开启请求 debug 日志的签名 header ，值由 SignForceDebug 生成
+	ForceDebugHeader = "X-Logging-Force-Debug"
+	// DefaultForceDebugTTL 注册 trace id 时默认的有效时长
+	DefaultForceDebugTTL = 10 * time.Minute
+	// MaxForceDebugTTL ForceDebugHeader 签名的最长有效时长
+	MaxForceDebugTTL = time.Hour
+
+	// forceDebugFieldKey 标记 logger 开启 debug 的字段 key ，该字段不会被打印
+	forceDebugFieldKey = "__logging_force_debug"
+)
+
+var (
+	// ForceDebugKeyname 开启 debug 日志的标记在 context 中的 key
+	ForceDebugKeyname Ctxkey = "force_debug"
+
+	// forceDebugTraceIDs 注册的 trace id 和到期时间
+	forceDebugTraceIDs      = map[string]time.Time{}
+	forceDebugTraceIDsMutex sync.Mutex
+)
+
+// forceDebugField 标记 logger 开启 debug ， SkipType 的字段不会被 encoder 打印，由 namedLevelCore 识别
+func forceDebugField() zap.Field {
+	return zap.Field{Key: forceDebugFieldKey, Type: zapcore.SkipType}
+}
+
+// isForceDebugField 是否为标记 logger 开启 debug 的字段
+func isForceDebugField(f zapcore.Field) bool {
+	return f.Type == zapcore.SkipType && f.Key == forceDebugFieldKey
+}
+
+// SignForceDebug 使用 secret 为 trace id 生成在 expiresAt 之前有效的 ForceDebugHeader 的值
+// 签名只对使用该 trace id 的请求有效， expiresAt 超过 MaxForceDebugTTL 时校验失败
+func SignForceDebug(secret, traceID string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expires + "." + forceDebugSignature(secret, traceID, expires)
+}
+
+// VerifyForceDebug 校验 ForceDebugHeader 的值对 trace id 是否有效， secret 或 trace id 为空时总是返回 false
+// 已过期或有效期超过 MaxForceDebugTTL 的值无效
+func VerifyForceDebug(secret, traceID, value string) bool {
+	if secret == "" || traceID == "" || value == "" {
+		return false
+	}
+	expires, signature, found := strings.Cut(value, ".")
+	if !found {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return false
+	}
+	now := time.Now().Unix()
+	if now > expiresAt || expiresAt-now > int64(MaxForceDebugTTL/time.Second) {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(forceDebugSignature(secret, traceID, expires)))
+}
+
+func forceDebugSignature(secret, traceID, expires string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(traceID + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AddForceDebugTraceID 注册 trace id ，在 ttl 时间内使用该 trace id 创建的 ctx logger 会打印 debug 日志
+// ttl 小于等于 0 时使用 DefaultForceDebugTTL
+func AddForceDebugTraceID(traceID string, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultForceDebugTTL
+	}
+	Warn(nil, "Add logging force debug trace id", zap.String("force_debug_trace_id", traceID), zap.Duration("ttl", ttl))
+	forceDebugTraceIDsMutex.Lock()
+	defer forceDebugTraceIDsMutex.Unlock()
+	forceDebugTraceIDs[traceID] = time.Now().Add(ttl)
+}
+
+// RemoveForceDebugTraceID 删除注册的 trace id
+func RemoveForceDebugTraceID(traceID string) {
+	Warn(nil, "Remove logging force debug trace id", zap.String("force_debug_trace_id", traceID))
+	forceDebugTraceIDsMutex.Lock()
+	defer forceDebugTraceIDsMutex.Unlock()
+	delete(forceDebugTraceIDs, traceID)
+}
+
+// ForceDebugTraceIDs 返回当前注册的 trace id 和到期时间
+func ForceDebugTraceIDs() map[string]time.Time {
+	forceDebugTraceIDsMutex.Lock()
+	defer forceDebugTraceIDsMutex.Unlock()
+	traceIDs := make(map[string]time.Time, len(forceDebugTraceIDs))
+	now := time.Now()
+	for traceID, expiresAt := range forceDebugTraceIDs {
+		if now.After(expiresAt) {
+			delete(forceDebugTraceIDs, traceID)
+			continue
+		}
+		traceIDs[traceID] = expiresAt
+	}
+	return traceIDs
+}
+
+// IsForceDebugTraceID trace id 是否已注册且未到期
+func IsForceDebugTraceID(traceID string) bool {
+	forceDebugTraceIDsMutex.Lock()
+	defer forceDebugTraceIDsMutex.Unlock()
+	if len(forceDebugTraceIDs) == 0 {
+		return false
+	}
+	expiresAt, exists := forceDebugTraceIDs[traceID]
+	if !exists {
+		return false
+	}
+	if time.Now().After(expiresAt) {
+		delete(forceDebugTraceIDs, traceID)
+		return false
+	}
+	return true
+}
+
+// CtxForceDebug context 是否开启了 debug 日志
+func CtxForceDebug(c context.Context) bool {
+	if c == nil {
+		return false
+	}
+	if gc, ok := c.(*gin.Context); ok {
+		if gc.GetBool(string(ForceDebugKeyname)) {
+			return true
+		}
+	}
+	forceDebug, _ := c.Value(ForceDebugKeyname).(bool)
+	return forceDebug
+}
+
+// WithForceDebug 返回开启了 debug 日志的 context ， context 中已有 ctx logger 时替换为开启 debug 的 logger
+func WithForceDebug(c context.Context) context.Context {
+	if c == nil {
+		c = context.Background()
+	}
+	if gc, ok := c.(*gin.Context); ok {
+		gc.Set(string(ForceDebugKeyname), true)
+		if ctxLogger, ok := ctxStoredLogger(gc); ok {
+			gc.Set(string(CtxLoggerName), ctxLogger.With(forceDebugField()))
+		}
+		return gc
+	}
+	if ctxLogger, ok := ctxStoredLogger(c); ok {
+		c = withCtxLogger(c, ctxLogger.With(forceDebugField()))
+	}
+	return context.WithValue(c, ForceDebugKeyname, true)
+}
+
+// forceDebugPayload 注册 trace id 的 http 请求和响应内容
+type forceDebugPayload struct {
+	TraceID  string            `json:"trace_id,omitempty"`
+	TTL      string            `json:"ttl,omitempty"`
+	TraceIDs map[string]string `json:"trace_ids,omitempty"` // 响应中注册的 trace id 和到期时间
+	Errmsg   string            `json:"error,omitempty"`
+}
+
+// forceDebugHandler 查询和注册开启 debug 日志的 trace id
+// curl -X GET http://host:port/force_debug
+// curl -X PUT http://host:port/force_debug -d '{"trace_id":"xxx","ttl":"10m"}'
+// curl -X DELETE http://host:port/force_debug -d '{"trace_id":"xxx"}'
+func forceDebugHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := jsoniter.NewEncoder(w)
+	fail := func(code int, err error) {
+		w.WriteHeader(code)
+		enc.Encode(forceDebugPayload{Errmsg: err.Error()})
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost, http.MethodDelete:
+		var req forceDebugPayload
+		if err := jsoniter.NewDecoder(r.Body).Decode(&req); err != nil {
+			fail(http.StatusBadRequest, fmt.Errorf("request body must be json: %w", err))
+			return
+		}
+		if req.TraceID == "" {
+			fail(http.StatusBadRequest, fmt.Errorf("must specify trace_id"))
+			return
+		}
+		if r.Method == http.MethodDelete {
+			RemoveForceDebugTraceID(req.TraceID)
+			break
+		}
+		var ttl time.Duration
+		if req.TTL != "" {
+			var err error
+			if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+				fail(http.StatusBadRequest, fmt.Errorf("invalid ttl: %s", req.TTL))
+				return
+			}
+		}
+		AddForceDebugTraceID(req.TraceID, ttl)
+	default:
+		fail(http.StatusMethodNotAllowed, fmt.Errorf("only GET, PUT, POST and DELETE are supported"))
+		return
+	}
+
+	rsp := forceDebugPayload{TraceIDs: map[string]string{}}
+	for traceID, expiresAt := range ForceDebugTraceIDs() {
+		rsp.TraceIDs[traceID] = expiresAt.Format(time.RFC3339)
+	}
+	enc.Encode(rsp)
+}
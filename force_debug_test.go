@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestVerifyForceDebug(t *testing.T) {
+	value := SignForceDebug("secret", "trace-id", time.Now().Add(time.Minute))
+	if !VerifyForceDebug("secret", "trace-id", value) {
+		t.Fatal("signed value should be valid")
+	}
+	if VerifyForceDebug("other", "trace-id", value) || VerifyForceDebug("", "trace-id", value) {
+		t.Fatal("value signed by other secret should be invalid")
+	}
+	if VerifyForceDebug("secret", "other-trace-id", value) || VerifyForceDebug("secret", "", value) {
+		t.Fatal("value should only be valid for the signed trace id")
+	}
+	if VerifyForceDebug("secret", "trace-id", SignForceDebug("secret", "trace-id", time.Now().Add(-time.Minute))) {
+		t.Fatal("expired value should be invalid")
+	}
+	if VerifyForceDebug("secret", "trace-id", SignForceDebug("secret", "trace-id", time.Now().Add(MaxForceDebugTTL+time.Minute))) {
+		t.Fatal("value exceeding max ttl should be invalid")
+	}
+	if VerifyForceDebug("secret", "trace-id", "abc") {
+		t.Fatal("malformed value should be invalid")
+	}
+}
+
+func TestForceDebugCtxLogger(t *testing.T) {
+	obsCore, logs := observer.New(zap.DebugLevel)
+	l := zap.New(newNamedLevelCore(obsCore, zap.NewAtomicLevelAt(zap.InfoLevel)))
+
+	_, ctxLogger := NewCtxLogger(context.Background(), l, "normal")
+	ctxLogger.Debug("normal debug")
+
+	ctx, ctxLogger := NewCtxLogger(WithForceDebug(context.Background()), l, "forced")
+	ctxLogger.Debug("forced debug")
+	CtxLogger(ctx).Named("gorm").With(zap.String("k", "v")).Debug("forced gorm debug")
+	if !CtxForceDebug(ctx) {
+		t.Fatal("ctx should be force debug")
+	}
+
+	AddForceDebugTraceID("registered", time.Minute)
+	t.Cleanup(func() { RemoveForceDebugTraceID("registered") })
+	_, ctxLogger = NewCtxLogger(context.Background(), l, "registered")
+	ctxLogger.Debug("registered debug")
+
+	if logs.FilterMessage("normal debug").Len() != 0 {
+		t.Fatal("normal ctx logger should not print debug log")
+	}
+	for _, msg := range []string{"forced debug", "forced gorm debug", "registered debug"} {
+		entries := logs.FilterMessage(msg).All()
+		if len(entries) != 1 {
+			t.Fatal(msg, "should be printed")
+		}
+		for _, f := range entries[0].Context {
+			if isForceDebugField(f) {
+				t.Fatal("force debug field should not be printed")
+			}
+		}
+	}
+
+	if _, exists := ForceDebugTraceIDs()["registered"]; !exists {
+		t.Fatal("registered trace id should be listed")
+	}
+	AddForceDebugTraceID("expired", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if IsForceDebugTraceID("expired") {
+		t.Fatal("expired trace id should not be force debug")
+	}
+}
+
+func TestGinLoggerForceDebug(t *testing.T) {
+	restoreGlobalLogger(t)
+	path := filepath.Join(t.TempDir(), "gin.log")
+	if err := ReloadLogger(Options{Level: "info", OutputPaths: []string{path}}); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	app.Use(GinLoggerWithConfig(GinLoggerConfig{ForceDebugSecret: "secret"}))
+	app.GET("/debug", func(c *gin.Context) {
+		Debug(c, "handler debug "+c.GetHeader("X-Name"))
+		c.String(http.StatusOK, "ok")
+	})
+	do := func(name, traceID, signature string) {
+		req := httptest.NewRequest(http.MethodGet, "/debug", nil)
+		req.Header.Set("X-Name", name)
+		req.Header.Set(string(TraceIDKeyname), traceID)
+		if signature != "" {
+			req.Header.Set(ForceDebugHeader, signature)
+		}
+		app.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	signature := SignForceDebug("secret", "force-debug-trace-id", time.Now().Add(time.Minute))
+	do("unsigned", "force-debug-trace-id", "")
+	do("invalid", "force-debug-trace-id", "123.abc")
+	do("other", "other-trace-id", signature)
+	do("signed", "force-debug-trace-id", signature)
+
+	content := readLogFile(t, path)
+	if !strings.Contains(content, "handler debug signed") {
+		t.Fatal("signed request should print debug log")
+	}
+	if strings.Contains(content, "handler debug unsigned") || strings.Contains(content, "handler debug invalid") {
+		t.Fatal("unsigned request should not print debug log")
+	}
+	if strings.Contains(content, "handler debug other") {
+		t.Fatal("signature should not be valid for other trace id")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"reflect"
 	"regexp"
 	"time"
 
@@ -56,6 +57,12 @@ func GetGinTraceIDFromQueryString(c *gin.Context) string {
 	return c.Query(string(TraceIDKeyname))
 }
 
+// GetGinTraceIDFromTraceparent 从 gin 请求 context 中的 OpenTelemetry span 或 W3C traceparent header 中获取 trace id
+func GetGinTraceIDFromTraceparent(c *gin.Context) string {
+	traceID, _, _ := ctxW3CSpan(c)
+	return traceID
+}
+
 // GetGinTraceIDFromPostForm 从 gin 的 postform 中获取 key 为 TraceIDKeyname 的值作为 traceid
 func GetGinTraceIDFromPostForm(c *gin.Context) string {
 	return c.PostForm(string(TraceIDKeyname))
@@ -118,9 +125,16 @@ type GinLoggerConfig struct {
 	SkipPaths []string
 	// SkipPathRegexps skip path by regexp
 	SkipPathRegexps []string
-	// TraceIDFunc 获取或生成 trace id 的函数
+	// TraceIDFunc 获取或生成 trace id 的函数，返回的 trace id 不经过 TraceIDValidator 校验，
+	// 但不满足 IsSafeTraceID 时同样会被替换，返回空字符串时使用 TraceIDGenerator 生成
 	// Optional.
 	TraceIDFunc func(context.Context) string
+	// TraceIDGenerator 请求中没有 trace id 或 trace id 不合法时生成 trace id
+	// Optional. Default value is logging.GetTraceIDGenerator()
+	TraceIDGenerator TraceIDGenerator
+	// TraceIDValidator 校验请求中的 trace id ，不合法时使用 TraceIDGenerator 生成新的 trace id
+	// Optional. Default value is logging.GetTraceIDValidator()
+	TraceIDValidator TraceIDValidator
 	// InitFieldsFunc 获取 logger 初始字段方法 key 为字段名 value 为字段值
 	InitFieldsFunc func(context.Context) map[string]interface{}
 	// 是否使用详细模式打印日志，记录更多字段信息
@@ -145,8 +159,16 @@ type GinLoggerConfig struct {
 	// Optional.
 	EnableResponseBody bool
 
+	// Redactor 对打印的 query 、 header 、 form 、 body 、 context keys 进行脱敏
+	// Optional. Default value is logging.DefaultRedactor
+	Redactor *Redactor
+
 	// 慢请求时间阈值 请求处理时间超过该值则使用 Error 级别打印日志
 	SlowThreshold time.Duration
+
+	// ForceDebugSecret 校验 ForceDebugHeader 签名的密钥，签名有效时该请求的 ctx logger 打印 debug 日志
+	// Optional. 为空时不校验 header ，只能通过注册 trace id 开启
+	ForceDebugSecret string
 }
 
 // GinLogger 以默认配置生成 gin 的 Logger 中间件
@@ -185,7 +207,8 @@ func defaultGinLogFormatter(c context.Context, m GinLogDetails) string {
 	return msg
 }
 
-func defaultGinTraceIDFunc(c context.Context) (traceID string) {
+// ginRequestTraceID 依次从请求 header 、 traceparent 、 form 、 query string 和 context 中获取 trace id ，没有时返回空字符串
+func ginRequestTraceID(c context.Context) (traceID string) {
 	if c == nil {
 		c = context.Background()
 	}
@@ -196,6 +219,10 @@ func defaultGinTraceIDFunc(c context.Context) (traceID string) {
 		if traceID != "" {
 			return
 		}
+		traceID = GetGinTraceIDFromTraceparent(gc)
+		if traceID != "" {
+			return
+		}
 		traceID = GetGinTraceIDFromPostForm(gc)
 		if traceID != "" {
 			return
@@ -205,8 +232,7 @@ func defaultGinTraceIDFunc(c context.Context) (traceID string) {
 			return
 		}
 	}
-	traceID = CtxTraceID(c)
-	return
+	return lookupTraceID(c)
 }
 
 // GinLoggerWithConfig 根据配置信息生成 gin 的 Logger 中间件
@@ -218,9 +244,9 @@ func GinLoggerWithConfig(conf GinLoggerConfig) gin.HandlerFunc {
 	if formatter == nil {
 		formatter = defaultGinLogFormatter
 	}
-	getTraceID := conf.TraceIDFunc
-	if getTraceID == nil {
-		getTraceID = defaultGinTraceIDFunc
+	validator := conf.TraceIDValidator
+	if validator == nil {
+		validator = GetTraceIDValidator()
 	}
 
 	var skip map[string]struct{}
@@ -242,23 +268,78 @@ func GinLoggerWithConfig(conf GinLoggerConfig) gin.HandlerFunc {
 	if conf.SlowThreshold.Seconds() <= 0 {
 		conf.SlowThreshold = defaultGinSlowThreshold
 	}
+	redactor := conf.Redactor
+	if redactor == nil {
+		redactor = DefaultRedactor
+	}
 
 	return func(c *gin.Context) {
 		start := time.Now()
 
-		traceID := getTraceID(c)
+		// 请求中没有 trace id 或 trace id 不合法时生成新的 trace id ，不合法的 trace id 截断后记录在单独的字段中
+		// TraceIDFunc 返回的 trace id 不使用 TraceIDValidator 校验，但可能直接来自请求（如 GetGinTraceIDFromHeader ），同样需要可以安全写入日志
+		var traceID, invalidTraceID string
+		if conf.TraceIDFunc != nil {
+			if traceID = conf.TraceIDFunc(c); traceID != "" && !IsSafeTraceID(traceID) {
+				invalidTraceID = truncateTraceID(traceID)
+				traceID = ""
+			}
+		} else if traceID = ginRequestTraceID(c); traceID != "" && !validTraceID(traceID, validator) {
+			invalidTraceID = truncateTraceID(traceID)
+			traceID = ""
+		}
+		if traceID == "" {
+			generator := conf.TraceIDGenerator
+			if generator == nil {
+				generator = GetTraceIDGenerator()
+			}
+			traceID = generator.NewTraceID()
+		}
+		// trace id 符合 W3C 格式时为本次请求生成新的 span id ， W3C traceparent 中调用方的 span id 作为 parent span id
+		w3cTraceID, incomingSpanID, traceFlags := ctxW3CSpan(c)
+		spanID, parentSpanID := newServerSpan(c.Request.Context(), traceID, w3cTraceID, incomingSpanID)
+		c.Set(string(TraceIDKeyname), traceID)
+		if spanID != "" {
+			c.Set(string(SpanIDKeyname), spanID)
+		}
+		if parentSpanID != "" {
+			c.Set(string(ParentSpanIDKeyname), parentSpanID)
+		}
 		// 设置 trace id 到 request header 中
 		c.Request.Header.Set(string(TraceIDKeyname), traceID)
 		// 设置 trace id 到 response header 中
 		c.Writer.Header().Set(string(TraceIDKeyname), traceID)
+		// 设置 traceparent 和 tracestate 到 request header 和 response header 中
+		if traceparent := FormatTraceparent(traceID, spanID, traceFlags); traceparent != "" {
+			c.Request.Header.Set(TraceparentHeader, traceparent)
+			c.Writer.Header().Set(TraceparentHeader, traceparent)
+			if tracestate := CtxTracestate(c); tracestate != "" {
+				c.Set(string(TracestateKeyname), tracestate)
+				c.Writer.Header().Set(TracestateHeader, tracestate)
+			}
+		}
+		// 请求携带对本次请求 trace id 有效的签名 header 时开启 debug 日志
+		if VerifyForceDebug(conf.ForceDebugSecret, traceID, c.GetHeader(ForceDebugHeader)) {
+			c.Set(string(ForceDebugKeyname), true)
+		}
 		// 设置 trace id 和 ctxLogger 到 context 中
 		ginLogger := CloneLogger("gin")
+		if invalidTraceID != "" {
+			ginLogger = ginLogger.With(zap.String(string(InvalidTraceIDKeyname), invalidTraceID))
+		}
 		if conf.InitFieldsFunc != nil {
 			for k, v := range conf.InitFieldsFunc(c) {
 				ginLogger = ginLogger.With(zap.Any(k, v))
 			}
 		}
 		_, ctxLogger := NewCtxLogger(c, ginLogger, traceID)
+		// 设置 trace id 和 ctxLogger 到 request context 中，使用 c.Request.Context() 的 GormLogger 、 http client 和请求中创建的 goroutine 使用相同的 trace id
+		// 不能使用 NewCtxLogger 返回的基于 gin.Context 的 context ，它不会继承 request context 的取消和超时
+		reqCtx := withCtxValues(c.Request.Context(), ctxLogger, traceID, spanID, parentSpanID, c.GetBool(string(ForceDebugKeyname)))
+		if tracestate := c.GetString(string(TracestateKeyname)); tracestate != "" {
+			reqCtx = context.WithValue(reqCtx, TracestateKeyname, tracestate)
+		}
+		c.Request = c.Request.WithContext(reqCtx)
 
 		// 获取请求信息
 		details := GinLogDetails{
@@ -278,21 +359,23 @@ func GinLoggerWithConfig(conf GinLoggerConfig) gin.HandlerFunc {
 			ContentType: c.ContentType(),
 			HandlerName: c.HandlerName(),
 		}
+		// query 中可能带有 token 等敏感信息
+		if query := redactRawQuery(redactor, details.Query); query != details.Query {
+			details.Query = query
+			details.RequestURI = details.Path + "?" + query
+		}
 
 		// 获取并保存原始请求 body
 		if conf.EnableRequestBody {
-			body := GetGinRequestBody(c)
-			if err := jsoniter.Unmarshal(body, &details.RequestBody); err != nil {
-				details.RequestBody = string(body)
-			}
+			details.RequestBody = redactor.Redact("request_body", unmarshalBody(GetGinRequestBody(c)))
 		}
 		// 获取并保存原始请求 form
 		if conf.EnableRequestForm {
-			details.RequestForm = c.Request.Form
+			details.RequestForm, _ = redactor.Redact("request_form", c.Request.Form).(url.Values)
 		}
 		// 获取并保存原始请求 header
 		if conf.EnableRequestHeader {
-			details.RequestHeader = c.Request.Header
+			details.RequestHeader, _ = redactor.Redact("request_header", c.Request.Header).(http.Header)
 		}
 		rspBodyWriter := &responseBodyWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
 		if conf.EnableResponseBody {
@@ -322,7 +405,7 @@ func GinLoggerWithConfig(conf GinLoggerConfig) gin.HandlerFunc {
 			}
 			// 判断是否打印 context keys
 			if conf.EnableContextKeys {
-				details.ContextKeys = c.Keys
+				details.ContextKeys, _ = redactor.Redact("context_keys", c.Keys).(map[string]interface{})
 				accessLogger = accessLogger.With(zap.Any("context_keys", details.ContextKeys))
 			}
 			// 判断是否打印请求 header
@@ -339,9 +422,7 @@ func GinLoggerWithConfig(conf GinLoggerConfig) gin.HandlerFunc {
 			}
 			// 判断是否打印响应 body
 			if conf.EnableResponseBody {
-				if err := jsoniter.Unmarshal(rspBodyWriter.body.Bytes(), &details.ResponseBody); err != nil {
-					details.ResponseBody = rspBodyWriter.body.String()
-				}
+				details.ResponseBody = redactor.Redact("response_body", unmarshalBody(rspBodyWriter.body.Bytes()))
 				accessLogger = accessLogger.With(zap.Any("response_body", details.ResponseBody))
 			}
 
@@ -372,19 +453,19 @@ func GinLoggerWithConfig(conf GinLoggerConfig) gin.HandlerFunc {
 				errLogger := detailsLogger.Named("err")
 				// 无视配置开关，打印全部能搜集的信息
 				if len(details.ContextKeys) == 0 {
-					errLogger = errLogger.With(zap.Any("context_keys", c.Keys))
+					errLogger = errLogger.With(zap.Any("context_keys", redactor.Redact("context_keys", c.Keys)))
 				}
 				if len(details.RequestHeader) == 0 {
-					errLogger = errLogger.With(zap.Any("request_header", c.Request.Header))
+					errLogger = errLogger.With(zap.Any("request_header", redactor.Redact("request_header", c.Request.Header)))
 				}
 				if len(details.RequestForm) == 0 {
-					errLogger = errLogger.With(zap.Any("request_form", c.Request.Form))
+					errLogger = errLogger.With(zap.Any("request_form", redactor.Redact("request_form", c.Request.Form)))
 				}
 				if details.RequestBody == nil {
-					errLogger = errLogger.With(zap.String("request_body", string(GetGinRequestBody(c))))
+					errLogger = errLogger.With(zap.String("request_body", redactBodyString(redactor, "request_body", GetGinRequestBody(c))))
 				}
 				if details.ResponseBody == nil {
-					errLogger = errLogger.With(zap.String("response_body", rspBodyWriter.body.String()))
+					errLogger = errLogger.With(zap.String("response_body", redactBodyString(redactor, "response_body", rspBodyWriter.body.Bytes())))
 				}
 				log = errLogger.Error
 			} else if details.StatusCode >= http.StatusBadRequest {
@@ -443,6 +524,44 @@ func GetGinRequestBody(c *gin.Context) []byte {
 	return requestBody
 }
 
+// unmarshalBody 将 JSON 格式的 body 解析为对象，不是 JSON 时返回字符串
+func unmarshalBody(body []byte) interface{} {
+	var v interface{}
+	if err := jsoniter.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	return v
+}
+
+// redactBodyString 对 body 脱敏后返回字符串形式的 body
+func redactBodyString(redactor *Redactor, key string, body []byte) string {
+	v := unmarshalBody(body)
+	if _, ok := v.(string); ok {
+		return redactor.RedactString(string(body))
+	}
+	redacted, err := jsoniter.MarshalToString(redactor.Redact(key, v))
+	if err != nil {
+		return string(body)
+	}
+	return redacted
+}
+
+// redactRawQuery 对 url query 中的参数脱敏，无需脱敏时返回原始 query
+func redactRawQuery(redactor *Redactor, rawQuery string) string {
+	if rawQuery == "" {
+		return rawQuery
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return redactor.RedactString(rawQuery)
+	}
+	redacted, _ := redactor.Redact("query", values).(url.Values)
+	if reflect.DeepEqual(values, redacted) {
+		return rawQuery
+	}
+	return redacted.Encode()
+}
+
 // 用于记录响应 body
 type responseBodyWriter struct {
 	gin.ResponseWriter
@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func hello(c *gin.Context) {
@@ -56,3 +59,30 @@ func TestGinLoggerWithConfig(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestGinLoggerRequestContext(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	app.Use(GinLoggerWithConfig(GinLoggerConfig{TraceIDFunc: func(context.Context) string { return "request-ctx-trace-id" }}))
+	var reqCtx context.Context
+	app.GET("/ctx", func(c *gin.Context) {
+		reqCtx = c.Request.Context()
+		CtxLogger(reqCtx).Info("request ctx log")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ctx", nil).WithContext(ctx))
+
+	if CtxTraceID(reqCtx) != "request-ctx-trace-id" {
+		t.Fatal("request context should have the gin trace id", CtxTraceID(reqCtx))
+	}
+	entries := logs.FilterMessage("request ctx log").AllUntimed()
+	if len(entries) != 1 || entries[0].ContextMap()[string(TraceIDKeyname)] != "request-ctx-trace-id" {
+		t.Fatal("request context logger should have the gin trace id", entries)
+	}
+	cancel()
+	if reqCtx.Err() == nil {
+		t.Fatal("request context should keep the request cancellation")
+	}
+}
@@ -12,175 +12,149 @@ import (
 	"go.uber.org/zap"
 )
 
+// helperLogger 返回全局方法使用的 logger ，不需要每次打印日志时都重新创建
+func helperLogger(c context.Context) *zap.Logger {
+	h, fields := resolveCtxHelper(c)
+	if h != nil {
+		return h.init().logger
+	}
+	return rootHelper.Load().logger.With(fields...)
+}
+
+// helperSugaredLogger 返回全局方法使用的 sugared logger ，不需要每次打印日志时都重新创建
+func helperSugaredLogger(c context.Context) *zap.SugaredLogger {
+	h, fields := resolveCtxHelper(c)
+	if h != nil {
+		return h.init().sugar
+	}
+	args := make([]interface{}, len(fields))
+	for i, f := range fields {
+		args[i] = f
+	}
+	return rootHelper.Load().sugar.With(args...)
+}
+
 // Debugs 尝试从 Context 中获取带 trace id 的 sugared logger 来记录 debug 级别的日志
 // logging.Debugs(nil, "abc", 123)
 func Debugs(c context.Context, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Debug(args...)
+	helperSugaredLogger(c).Debug(args...)
 }
 
 // Infos 尝试从 Context 中获取带 trace id 的 sugared logger 来记录 info 级别的日志
 func Infos(c context.Context, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Info(args...)
+	helperSugaredLogger(c).Info(args...)
 }
 
 // Warns 尝试从 Context 中获取带 trace id 的 sugared logger 来记录 warn 级别的日志
 func Warns(c context.Context, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Warn(args...)
+	helperSugaredLogger(c).Warn(args...)
 }
 
 // Errors 尝试从 Context 中获取带 trace id 的 sugared logger 来记录 Error 级别的日志
 func Errors(c context.Context, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Error(args...)
+	helperSugaredLogger(c).Error(args...)
 }
 
 // Panics 尝试从 Context 中获取带 trace id 的 sugared logger 来记录 Panic 级别的日志
 func Panics(c context.Context, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Panic(args...)
+	helperSugaredLogger(c).Panic(args...)
 }
 
 // Fatals 尝试从 Context 中获取带 trace id 的 sugared logger 来记录 Fatal 级别的日志
 func Fatals(c context.Context, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Fatal(args...)
+	helperSugaredLogger(c).Fatal(args...)
 }
 
 // Debugf 尝试从 Context 中获取带 trace id 的 sugared logger 来模板字符串记录 debug 级别的日志
 // logging.Debugf(nil, "str:%s", "abd")
 func Debugf(c context.Context, template string, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Debugf(template, args...)
+	helperSugaredLogger(c).Debugf(template, args...)
 }
 
 // Infof 尝试从 Context 中获取带 trace id 的 sugared logger 来模板字符串记录 info 级别的日志
 func Infof(c context.Context, template string, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Infof(template, args...)
+	helperSugaredLogger(c).Infof(template, args...)
 }
 
 // Warnf 尝试从 Context 中获取带 trace id 的 sugared logger 来模板字符串记录 warn 级别的日志
 func Warnf(c context.Context, template string, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Warnf(template, args...)
+	helperSugaredLogger(c).Warnf(template, args...)
 }
 
 // Errorf 尝试从 Context 中获取带 trace id 的 sugared logger 来模板字符串记录 error 级别的日志
 func Errorf(c context.Context, template string, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Errorf(template, args...)
+	helperSugaredLogger(c).Errorf(template, args...)
 }
 
 // Panicf 尝试从 Context 中获取带 trace id 的 sugared logger 来模板字符串记录 panic 级别的日志
 func Panicf(c context.Context, template string, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Panicf(template, args...)
+	helperSugaredLogger(c).Panicf(template, args...)
 }
 
 // Fatalf 尝试从 Context 中获取带 trace id 的 sugared logger 来模板字符串记录 fatal 级别的日志
 func Fatalf(c context.Context, template string, args ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Fatalf(template, args...)
+	helperSugaredLogger(c).Fatalf(template, args...)
 }
 
 // Debugw 尝试从 Context 中获取带 trace id 的 sugared logger 来 kv 记录 debug 级别的日志
 // logging.Debugw(nil, "msg", "k1", "v1", "k2", "v2")
 func Debugw(c context.Context, msg string, keysAndValues ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Debugw(msg, keysAndValues...)
+	helperSugaredLogger(c).Debugw(msg, keysAndValues...)
 }
 
 // Infow 尝试从 Context 中获取带 trace id 的 sugared logger 来 kv 记录 info 级别的日志
 func Infow(c context.Context, msg string, keysAndValues ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Infow(msg, keysAndValues...)
+	helperSugaredLogger(c).Infow(msg, keysAndValues...)
 }
 
 // Warnw 尝试从 Context 中获取带 trace id 的 sugared logger 来 kv 记录 warn 级别的日志
 func Warnw(c context.Context, msg string, keysAndValues ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Warnw(msg, keysAndValues...)
+	helperSugaredLogger(c).Warnw(msg, keysAndValues...)
 }
 
 // Errorw 尝试从 Context 中获取带 trace id 的 sugared logger 来 kv 记录 error 级别的日志
 func Errorw(c context.Context, msg string, keysAndValues ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Errorw(msg, keysAndValues...)
+	helperSugaredLogger(c).Errorw(msg, keysAndValues...)
 }
 
 // Panicw 尝试从 Context 中获取带 trace id 的 sugared logger 来 kv 记录 panic 级别的日志
 func Panicw(c context.Context, msg string, keysAndValues ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Panicw(msg, keysAndValues...)
+	helperSugaredLogger(c).Panicw(msg, keysAndValues...)
 }
 
 // Fatalw 尝试从 Context 中获取带 trace id 的 sugared logger 来 kv 记录 fatal 级别的日志
 func Fatalw(c context.Context, msg string, keysAndValues ...interface{}) {
-	slogger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1)).Sugar()
-	defer slogger.Sync()
-	slogger.Fatalw(msg, keysAndValues...)
+	helperSugaredLogger(c).Fatalw(msg, keysAndValues...)
 }
 
 // Debug 尝试从 Context 中获取带 trace id 的 logger 记录 debug 级别的日志
 func Debug(c context.Context, msg string, fields ...zap.Field) {
-	logger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1))
-	defer logger.Sync()
-	logger.Debug(msg, fields...)
+	helperLogger(c).Debug(msg, fields...)
 }
 
 // Info 尝试从 Context 中获取带 trace id 的 logger 记录 info 级别的日志
 func Info(c context.Context, msg string, fields ...zap.Field) {
-	logger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1))
-	defer logger.Sync()
-	logger.Info(msg, fields...)
+	helperLogger(c).Info(msg, fields...)
 }
 
 // Warn 尝试从 Context 中获取带 trace id 的 logger 记录 warn 级别的日志
 func Warn(c context.Context, msg string, fields ...zap.Field) {
-	logger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1))
-	defer logger.Sync()
-	logger.Warn(msg, fields...)
+	helperLogger(c).Warn(msg, fields...)
 }
 
 // Error 尝试从 Context 中获取带 trace id 的 logger 记录 error 级别的日志
 func Error(c context.Context, msg string, fields ...zap.Field) {
-	logger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1))
-	defer logger.Sync()
-	logger.Error(msg, fields...)
+	helperLogger(c).Error(msg, fields...)
 }
 
 // Panic 尝试从 Context 中获取带 trace id 的 logger 记录 panic 级别的日志
 func Panic(c context.Context, msg string, fields ...zap.Field) {
-	logger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1))
-	defer logger.Sync()
-	logger.Panic(msg, fields...)
+	helperLogger(c).Panic(msg, fields...)
 }
 
 // Fatal 尝试从 Context 中获取带 trace id 的 logger 记录 fatal 级别的日志
 func Fatal(c context.Context, msg string, fields ...zap.Field) {
-	logger := CtxLogger(c).WithOptions(zap.AddCallerSkip(1))
-	defer logger.Sync()
-	logger.Fatal(msg, fields...)
+	helperLogger(c).Fatal(msg, fields...)
 }
 
 // ExtraField 顺序传入 kv 对，返回以 extra 为 key ，传入的 kv 对组成的 map 为值的 zap Reflect Field
@@ -0,0 +1,41 @@
+//go:build !race
+
+// race detector 会产生额外的内存分配，只在没有开启 race 时检查
+
+package logging
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestHelpersAllocs(t *testing.T) {
+	encoderConfig := EncoderConfig
+	encoderConfig.EncodeTime = zapcore.EpochTimeEncoder
+	l := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), zapcore.AddSync(io.Discard), zap.DebugLevel))
+	defer ReplaceLogger(l)()
+	sugar := l.Sugar()
+	gin.SetMode(gin.ReleaseMode)
+	gc, _ := gin.CreateTestContext(httptest.NewRecorder())
+	gc.Request = httptest.NewRequest("GET", "/allocs", nil)
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("allocs"), "allocs-trace-id")
+	for _, c := range []context.Context{nil, context.Background(), ctx, gc} {
+		Info(c, "warm up")
+		if allocs := testing.AllocsPerRun(100, func() { Info(c, "allocs") }); allocs != 0 {
+			t.Errorf("Info should not allocate with %T, allocs: %v", c, allocs)
+		}
+		// Infow 和 Infof 的参数本身会产生内存分配，与直接使用 sugared logger 比较
+		if allocs, base := testing.AllocsPerRun(100, func() { Infow(c, "allocs", "k", "v") }), testing.AllocsPerRun(100, func() { sugar.Infow("allocs", "k", "v") }); allocs > base {
+			t.Errorf("Infow should not allocate more than sugared logger with %T, allocs: %v, base: %v", c, allocs, base)
+		}
+		if allocs, base := testing.AllocsPerRun(100, func() { Infof(c, "allocs %d", 1) }), testing.AllocsPerRun(100, func() { sugar.Infof("allocs %d", 1) }); allocs > base {
+			t.Errorf("Infof should not allocate more than sugared logger with %T, allocs: %v, base: %v", c, allocs, base)
+		}
+	}
+}
@@ -2,9 +2,16 @@ package logging
 
 import (
 	"context"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestGlobal(t *testing.T) {
@@ -33,3 +40,135 @@ func TestGlobal(t *testing.T) {
 	f := ExtraField("k1", "v1", "k2", 2)
 	t.Logf("%#v", f)
 }
+
+func TestGlobalHelperLogger(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core, zap.AddCaller()))()
+
+	gin.SetMode(gin.ReleaseMode)
+	gc, _ := gin.CreateTestContext(httptest.NewRecorder())
+	gc.Request = httptest.NewRequest("GET", "/helper", nil)
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("test"), "helper-trace-id")
+	for _, c := range []context.Context{nil, ctx, gc} {
+		Info(c, "Info")
+		Infof(c, "%s", "Infof")
+		Infow(c, "Infow", "k", "v")
+		Infos(c, "Infos")
+	}
+	Info(ctx, "Info")
+	Info(gc, "Info")
+
+	entries := logs.AllUntimed()
+	if len(entries) != 14 {
+		t.Fatal("invalid log count", len(entries))
+	}
+	for i, entry := range entries {
+		if !strings.HasSuffix(entry.Caller.File, "global_test.go") {
+			t.Fatal("caller should be the helper caller", entry.Message, entry.Caller.String())
+		}
+		// nil context 没有 trace id
+		if _, exists := entry.ContextMap()[string(TraceIDKeyname)]; exists != (i >= 4) {
+			t.Fatal("invalid helper log trace id", i, entry.ContextMap())
+		}
+	}
+	if entries[4].ContextMap()[string(TraceIDKeyname)] != "helper-trace-id" || entries[12].ContextMap()[string(TraceIDKeyname)] != "helper-trace-id" {
+		t.Fatal("helper should use ctx logger", entries[4].ContextMap())
+	}
+	if entries[13].ContextMap()["RequestURI"] != "/helper" || entries[8].ContextMap()["RequestURI"] != "/helper" {
+		t.Fatal("gin helper should have request fields", entries[13].ContextMap())
+	}
+	if entries[13].ContextMap()[string(TraceIDKeyname)] != entries[8].ContextMap()[string(TraceIDKeyname)] {
+		t.Fatal("gin helper should reuse ctx logger")
+	}
+}
+
+// benchmarkLogger 输出到文件的 logger ，用于对比每次打印日志后 Sync 的开销
+func benchmarkLogger(b *testing.B) {
+	l, closer, err := buildLogger(Options{OutputPaths: []string{filepath.Join(b.TempDir(), "bench.log")}}, zap.NewAtomicLevelAt(zap.DebugLevel))
+	if err != nil {
+		b.Fatal(err)
+	}
+	restore := ReplaceLogger(l)
+	b.Cleanup(func() {
+		restore()
+		closer()
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+}
+
+// BenchmarkInfoPerCallSync 修改前的全局方法：每次创建 logger 并 Sync
+func BenchmarkInfoPerCallSync(b *testing.B) {
+	benchmarkLogger(b)
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("bench"), "bench-trace-id")
+	for i := 0; i < b.N; i++ {
+		logger := CtxLogger(ctx).WithOptions(zap.AddCallerSkip(1))
+		logger.Info("bench", zap.Int("i", i))
+		logger.Sync()
+	}
+}
+
+func BenchmarkInfo(b *testing.B) {
+	benchmarkLogger(b)
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("bench"), "bench-trace-id")
+	for i := 0; i < b.N; i++ {
+		Info(ctx, "bench", zap.Int("i", i))
+	}
+}
+
+// BenchmarkInfofPerCallSync 修改前的全局方法：每次创建 sugared logger 并 Sync
+func BenchmarkInfofPerCallSync(b *testing.B) {
+	benchmarkLogger(b)
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("bench"), "bench-trace-id")
+	for i := 0; i < b.N; i++ {
+		slogger := CtxLogger(ctx).WithOptions(zap.AddCallerSkip(1)).Sugar()
+		slogger.Infof("bench %d", i)
+		slogger.Sync()
+	}
+}
+
+func BenchmarkInfof(b *testing.B) {
+	benchmarkLogger(b)
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("bench"), "bench-trace-id")
+	for i := 0; i < b.N; i++ {
+		Infof(ctx, "bench %d", i)
+	}
+}
+
+// BenchmarkHelpers 全局方法在不同 context 下的内存分配
+func BenchmarkHelpers(b *testing.B) {
+	restore := ReplaceLogger(zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(EncoderConfig), zapcore.AddSync(io.Discard), zap.DebugLevel)))
+	defer restore()
+	gin.SetMode(gin.ReleaseMode)
+	gc, _ := gin.CreateTestContext(httptest.NewRecorder())
+	gc.Request = httptest.NewRequest("POST", "/bench", strings.NewReader(`{"trace_id":"body-trace-id"}`))
+	NewCtxLogger(gc, CloneLogger("bench"), "")
+	contexts := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"nil", nil},
+		{"background", context.Background()},
+		{"gin", gc},
+	}
+	for _, c := range contexts {
+		b.Run("Info/"+c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Info(c.ctx, "bench")
+			}
+		})
+		b.Run("Infow/"+c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Infow(c.ctx, "bench", "k", "v")
+			}
+		})
+		b.Run("Infof/"+c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Infof(c.ctx, "bench %s", "v")
+			}
+		})
+	}
+}
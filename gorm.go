@@ -56,25 +56,30 @@ func (g GormLogger) CtxLogger(ctx context.Context) *zap.Logger {
 
 // Info 实现 gorm logger 接口方法
 func (g GormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
-	if g.logLevel <= zap.InfoLevel {
+	if g.enabled(ctx, zap.InfoLevel) {
 		g.CtxLogger(ctx).Sugar().Infof(msg, data...)
 	}
 }
 
 // Warn 实现 gorm logger 接口方法
 func (g GormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
-	if g.logLevel <= zap.WarnLevel {
+	if g.enabled(ctx, zap.WarnLevel) {
 		g.CtxLogger(ctx).Sugar().Warnf(msg, data...)
 	}
 }
 
 // Error 实现 gorm logger 接口方法
 func (g GormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
-	if g.logLevel <= zap.ErrorLevel {
+	if g.enabled(ctx, zap.ErrorLevel) {
 		g.CtxLogger(ctx).Sugar().Errorf(msg, data...)
 	}
 }
 
+// enabled 判断是否打印 lvl 级别的日志， ctx 开启了 debug 日志时全部打印
+func (g GormLogger) enabled(ctx context.Context, lvl zapcore.Level) bool {
+	return g.logLevel <= lvl || CtxForceDebug(ctx)
+}
+
 // Trace 实现 gorm logger 接口方法
 func (g GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
 	now := time.Now()
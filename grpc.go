@@ -0,0 +1,374 @@
+// grpc 的日志拦截器
+
+package logging
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// grpc prometheus labels
+	promGRPCLabels = []string{
+		"status_code",
+		"method",
+	}
+	promGRPCServerReqCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "grpc_server_req_count",
+			Help:      "grpc server request count",
+		}, promGRPCLabels,
+	)
+	promGRPCServerReqLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Name:      "grpc_server_req_latency",
+			Help:      "grpc server request latency in seconds",
+		}, promGRPCLabels,
+	)
+	promGRPCClientReqCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "grpc_client_req_count",
+			Help:      "grpc client request count",
+		}, promGRPCLabels,
+	)
+	promGRPCClientReqLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Name:      "grpc_client_req_latency",
+			Help:      "grpc client request latency in seconds",
+		}, promGRPCLabels,
+	)
+)
+
+// GRPCLoggerConfig grpc 日志拦截器支持的配置项字段定义
+type GRPCLoggerConfig struct {
+	// SkipMethods 不打印日志的 grpc 方法全名，如 /grpc.health.v1.Health/Check
+	// Optional.
+	SkipMethods []string
+	// SkipMethodRegexps skip method by regexp
+	// Optional.
+	SkipMethodRegexps []string
+	// TraceIDFunc 获取或生成 trace id 的函数，只在 server 端使用
+	// Optional. Default value is logging.defaultGRPCTraceIDFunc
+	TraceIDFunc func(context.Context) string
+	// InitFieldsFunc 获取 logger 初始字段方法 key 为字段名 value 为字段值，只在 server 端使用
+	// Optional.
+	InitFieldsFunc func(context.Context) map[string]interface{}
+	// CodeToLevel grpc 状态码对应的日志级别
+	// Optional. Default value is logging.GRPCCodeToLevel
+	CodeToLevel func(codes.Code) zapcore.Level
+	// 慢请求时间阈值 请求处理时间超过该值则使用 Warn 级别打印日志
+	// Optional. Default value is 3s
+	SlowThreshold time.Duration
+}
+
+// GRPCCodeToLevel 默认的 grpc 状态码对应的日志级别
+// OK 为 Info ，调用方导致的错误为 Warn ，服务端错误为 Error
+func GRPCCodeToLevel(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK:
+		return zap.InfoLevel
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange:
+		return zap.WarnLevel
+	default:
+		return zap.ErrorLevel
+	}
+}
+
+// GetGRPCTraceIDFromMetadata 从 grpc 的 incoming metadata 中获取 key 为 TraceIDKeyname 的值作为 trace id
+// 不存在时尝试从 W3C traceparent 中获取
+func GetGRPCTraceIDFromMetadata(c context.Context) string {
+	md, _ := metadata.FromIncomingContext(c)
+	if values := md.Get(string(TraceIDKeyname)); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	if values := md.Get(TraceparentHeader); len(values) > 0 {
+		traceID, _, _, _ := ParseTraceparent(values[0])
+		return traceID
+	}
+	return ""
+}
+
+func defaultGRPCTraceIDFunc(c context.Context) string {
+	if traceID := GetGRPCTraceIDFromMetadata(c); validTraceID(traceID, GetTraceIDValidator()) {
+		return traceID
+	}
+	return CtxTraceID(c)
+}
+
+// grpc 拦截器的公共部分
+type grpcLogger struct {
+	conf        GRPCLoggerConfig
+	skip        map[string]struct{}
+	skipRegexps []*regexp.Regexp
+}
+
+func newGRPCLogger(conf GRPCLoggerConfig) *grpcLogger {
+	if conf.TraceIDFunc == nil {
+		conf.TraceIDFunc = defaultGRPCTraceIDFunc
+	}
+	if conf.CodeToLevel == nil {
+		conf.CodeToLevel = GRPCCodeToLevel
+	}
+	if conf.SlowThreshold.Seconds() <= 0 {
+		conf.SlowThreshold = defaultGinSlowThreshold
+	}
+	g := &grpcLogger{conf: conf}
+	if length := len(conf.SkipMethods); length > 0 {
+		g.skip = make(map[string]struct{}, length)
+		for _, method := range conf.SkipMethods {
+			g.skip[method] = struct{}{}
+		}
+	}
+	for _, p := range conf.SkipMethodRegexps {
+		if r, err := regexp.Compile(p); err != nil {
+			Error(nil, "skip method regexps compile "+p+" error:"+err.Error())
+		} else {
+			g.skipRegexps = append(g.skipRegexps, r)
+		}
+	}
+	return g
+}
+
+func (g *grpcLogger) skipMethod(method string) bool {
+	if _, exists := g.skip[method]; exists {
+		return true
+	}
+	for _, p := range g.skipRegexps {
+		if p.MatchString(method) {
+			return true
+		}
+	}
+	return false
+}
+
+// serverContext 获取 trace id ，设置 ctx logger 到 context 中，并将 trace id 返回给调用方
+func (g *grpcLogger) serverContext(ctx context.Context) (context.Context, *zap.Logger) {
+	traceID := g.conf.TraceIDFunc(ctx)
+	// 生成本服务的 span id ， traceparent 属于同一个 trace 时其中的 span id 作为 parent span id
+	md, _ := metadata.FromIncomingContext(ctx)
+	var w3cTraceID, incomingSpanID string
+	if values := md.Get(TraceparentHeader); len(values) > 0 {
+		w3cTraceID, incomingSpanID, _, _ = ParseTraceparent(values[0])
+	}
+	spanID, parentSpanID := newServerSpan(ctx, traceID, w3cTraceID, incomingSpanID)
+	ctx = context.WithValue(ctx, TraceIDKeyname, traceID)
+	if spanID != "" {
+		ctx = context.WithValue(ctx, SpanIDKeyname, spanID)
+	}
+	if parentSpanID != "" {
+		ctx = context.WithValue(ctx, ParentSpanIDKeyname, parentSpanID)
+	}
+	if values := md.Get(TracestateHeader); len(values) > 0 {
+		ctx = context.WithValue(ctx, TracestateKeyname, values[0])
+	}
+
+	grpcLogger := CloneLogger("grpc")
+	// metadata 中的 trace id 不合法时截断后记录在单独的字段中
+	if mdTraceID := GetGRPCTraceIDFromMetadata(ctx); mdTraceID != "" && !validTraceID(mdTraceID, GetTraceIDValidator()) {
+		grpcLogger = grpcLogger.With(zap.String(string(InvalidTraceIDKeyname), truncateTraceID(mdTraceID)))
+	}
+	if g.conf.InitFieldsFunc != nil {
+		for k, v := range g.conf.InitFieldsFunc(ctx) {
+			grpcLogger = grpcLogger.With(zap.Any(k, v))
+		}
+	}
+	ctx, ctxLogger := NewCtxLogger(ctx, grpcLogger, traceID)
+	// 设置 trace id 到 response header 中
+	grpc.SetHeader(ctx, metadata.Pairs(string(TraceIDKeyname), traceID))
+	return ctx, ctxLogger
+}
+
+// clientContext 获取 trace id 并设置到 outgoing metadata 中
+func (g *grpcLogger) clientContext(ctx context.Context) (context.Context, *zap.Logger) {
+	// 保证 trace id 只生成一次，日志和 metadata 中使用同一个 trace id
+	traceID := CtxTraceID(ctx)
+	if ctxStoredTraceID(ctx) == "" {
+		ctx = context.WithValue(ctx, TraceIDKeyname, traceID)
+	}
+	var logger *zap.Logger
+	if ctxLogger, ok := ctxStoredLogger(ctx); ok {
+		logger = ctxLogger.Named("grpc_client")
+	} else {
+		_, logger = NewCtxLogger(ctx, CloneLogger("grpc_client"), traceID)
+	}
+
+	pairs := []string{string(TraceIDKeyname), traceID}
+	if traceparent := CtxTraceparent(ctx); traceparent != "" {
+		pairs = append(pairs, TraceparentHeader, traceparent)
+		if tracestate := CtxTracestate(ctx); tracestate != "" {
+			pairs = append(pairs, TracestateHeader, tracestate)
+		}
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...), logger
+}
+
+// log 打印访问日志并记录 prometheus 指标
+func (g *grpcLogger) log(logger *zap.Logger, method, peerAddr string, start time.Time, err error, promCount *prometheus.CounterVec, promLatency *prometheus.HistogramVec) {
+	if g.skipMethod(method) {
+		return
+	}
+	latency := time.Since(start).Seconds()
+	code := status.Code(err)
+	logger = logger.With(
+		zap.String("method", method),
+		zap.String("status_code", code.String()),
+		zap.Float64("latency_seconds", latency),
+		zap.String("peer", peerAddr),
+	)
+	if err != nil {
+		logger = logger.With(zap.Error(err))
+	}
+	msg := peerAddr + "|" + method + "|" + code.String()
+
+	// 慢请求使用 Warn 记录
+	if latency > g.conf.SlowThreshold.Seconds() {
+		logger.Warn(msg+" hit slow request.", zap.Float64("slow_threshold", g.conf.SlowThreshold.Seconds()))
+	} else if ce := logger.Check(g.conf.CodeToLevel(code), msg); ce != nil {
+		ce.Write()
+	}
+
+	// update prometheus info
+	labels := []string{code.String(), method}
+	promCount.WithLabelValues(labels...).Inc()
+	promLatency.WithLabelValues(labels...).Observe(latency)
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// GRPCUnaryServerInterceptor 根据配置信息生成 grpc server 的 unary 日志拦截器
+// 从 incoming metadata 中获取或生成 trace id ，将带 trace id 的 ctx logger 设置到 context 中，请求完成后根据状态码确定级别打印日志
+func GRPCUnaryServerInterceptor(conf GRPCLoggerConfig) grpc.UnaryServerInterceptor {
+	g := newGRPCLogger(conf)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, ctxLogger := g.serverContext(ctx)
+		rsp, err := handler(ctx, req)
+		g.log(ctxLogger.Named("access_logger"), info.FullMethod, peerAddr(ctx), start, err, promGRPCServerReqCount, promGRPCServerReqLatency)
+		return rsp, err
+	}
+}
+
+// GRPCStreamServerInterceptor 根据配置信息生成 grpc server 的 stream 日志拦截器
+func GRPCStreamServerInterceptor(conf GRPCLoggerConfig) grpc.StreamServerInterceptor {
+	g := newGRPCLogger(conf)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, ctxLogger := g.serverContext(ss.Context())
+		err := handler(srv, &grpcServerStream{ServerStream: ss, ctx: ctx})
+		g.log(ctxLogger.Named("access_logger"), info.FullMethod, peerAddr(ctx), start, err, promGRPCServerReqCount, promGRPCServerReqLatency)
+		return err
+	}
+}
+
+// GRPCUnaryClientInterceptor 根据配置信息生成 grpc client 的 unary 日志拦截器
+// 从 context 中获取 trace id 设置到 outgoing metadata 中，请求完成后根据状态码确定级别打印日志
+func GRPCUnaryClientInterceptor(conf GRPCLoggerConfig) grpc.UnaryClientInterceptor {
+	g := newGRPCLogger(conf)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		ctx, logger := g.clientContext(ctx)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		g.log(logger, method, cc.Target(), start, err, promGRPCClientReqCount, promGRPCClientReqLatency)
+		return err
+	}
+}
+
+// GRPCStreamClientInterceptor 根据配置信息生成 grpc client 的 stream 日志拦截器
+// stream 结束、出错或非 server stream 收到响应时打印日志
+func GRPCStreamClientInterceptor(conf GRPCLoggerConfig) grpc.StreamClientInterceptor {
+	g := newGRPCLogger(conf)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		ctx, logger := g.clientContext(ctx)
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		finish := func(err error) {
+			g.log(logger, method, cc.Target(), start, err, promGRPCClientReqCount, promGRPCClientReqLatency)
+		}
+		if err != nil {
+			finish(err)
+			return nil, err
+		}
+		return &grpcClientStream{ClientStream: cs, serverStreams: desc.ServerStreams, finish: finish}, nil
+	}
+}
+
+// grpcServerStream 替换 ServerStream 的 context 为带有 ctx logger 的 context
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context 实现 grpc.ServerStream 接口
+func (s *grpcServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcClientStream 在 stream 结束时打印日志
+type grpcClientStream struct {
+	grpc.ClientStream
+	// server 端是否为 stream ，不是时成功收到一条响应即表示 stream 结束
+	serverStreams bool
+	once          sync.Once
+	finish        func(error)
+}
+
+// done 只在第一次结束时打印日志， io.EOF 表示 stream 正常结束
+func (s *grpcClientStream) done(err error) {
+	s.once.Do(func() {
+		if err == io.EOF {
+			err = nil
+		}
+		s.finish(err)
+	})
+}
+
+// Header 实现 grpc.ClientStream 接口
+func (s *grpcClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.done(err)
+	}
+	return md, err
+}
+
+// CloseSend 实现 grpc.ClientStream 接口
+func (s *grpcClientStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err != nil {
+		s.done(err)
+	}
+	return err
+}
+
+// RecvMsg 实现 grpc.ClientStream 接口
+func (s *grpcClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.serverStreams {
+		s.done(err)
+	}
+	return err
+}
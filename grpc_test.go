@@ -0,0 +1,207 @@
+package logging
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestGRPCClient(t *testing.T, conf GRPCLoggerConfig) (grpc_health_v1.HealthClient, func()) {
+	lis := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(GRPCUnaryServerInterceptor(conf)),
+		grpc.StreamInterceptor(GRPCStreamServerInterceptor(conf)),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("serving", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(GRPCUnaryClientInterceptor(conf)),
+		grpc.WithStreamInterceptor(GRPCStreamClientInterceptor(conf)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return grpc_health_v1.NewHealthClient(conn), func() {
+		conn.Close()
+		srv.Stop()
+	}
+}
+
+func TestGRPCUnaryInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+
+	client, stop := newTestGRPCClient(t, GRPCLoggerConfig{})
+	defer stop()
+
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("test"), "grpc-trace-id")
+	var header metadata.MD
+	if _, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "serving"}, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if tid := header.Get(string(TraceIDKeyname)); len(tid) == 0 || tid[0] != "grpc-trace-id" {
+		t.Error("invalid response trace id", tid)
+	}
+	_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatal("should return not found", err)
+	}
+
+	entries := logs.FilterField(zap.String(string(TraceIDKeyname), "grpc-trace-id")).AllUntimed()
+	// server 和 client 各打印 2 条
+	if len(entries) != 4 {
+		t.Fatal("invalid entries count", len(entries), logs.AllUntimed())
+	}
+	for _, entry := range entries {
+		m := entry.ContextMap()
+		if m["method"] != "/grpc.health.v1.Health/Check" {
+			t.Error("invalid method", m)
+		}
+		expect := zap.InfoLevel
+		if m["status_code"] == codes.NotFound.String() {
+			expect = zap.WarnLevel
+		}
+		if entry.Level != expect {
+			t.Error("invalid level", entry.Level, m)
+		}
+	}
+}
+
+func TestGRPCStreamInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+
+	client, stop := newTestGRPCClient(t, GRPCLoggerConfig{SkipMethods: []string{"/grpc.health.v1.Health/Check"}})
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx, _ = NewCtxLogger(ctx, CloneLogger("test"), "grpc-stream-trace-id")
+	stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: "serving"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Fatal("should be canceled", err)
+	}
+	if _, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "serving"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logs.FilterField(zap.String("method", "/grpc.health.v1.Health/Watch")).AllUntimed()
+	if len(entries) == 0 || entries[0].ContextMap()[string(TraceIDKeyname)] != "grpc-stream-trace-id" {
+		t.Error("invalid stream entries", entries)
+	}
+	if n := logs.FilterField(zap.String("method", "/grpc.health.v1.Health/Check")).Len(); n != 0 {
+		t.Error("skip methods should not be logged", n)
+	}
+}
+
+// fakeClientStream 用于测试 client stream 的 grpc.ClientStream
+type fakeClientStream struct {
+	grpc.ClientStream
+	headerErr error
+	recvErr   error
+}
+
+func (s *fakeClientStream) Header() (metadata.MD, error) { return nil, s.headerErr }
+func (s *fakeClientStream) CloseSend() error             { return nil }
+func (s *fakeClientStream) SendMsg(interface{}) error    { return nil }
+func (s *fakeClientStream) RecvMsg(interface{}) error    { return s.recvErr }
+
+func TestGRPCClientStreamingInterceptor(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+
+	cc, err := grpc.Dial("bufnet", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	interceptor := GRPCStreamClientInterceptor(GRPCLoggerConfig{})
+	newStream := func(method string, fake *fakeClientStream) grpc.ClientStream {
+		desc := &grpc.StreamDesc{ClientStreams: true}
+		cs, err := interceptor(context.Background(), desc, cc, method, func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			return fake, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cs
+	}
+
+	// client stream 发送完成后成功收到响应即结束
+	cs := newStream("/test/Upload", &fakeClientStream{})
+	cs.SendMsg(nil)
+	cs.CloseSend()
+	if err := cs.RecvMsg(nil); err != nil {
+		t.Fatal(err)
+	}
+	entries := logs.FilterField(zap.String("method", "/test/Upload")).AllUntimed()
+	if len(entries) != 1 || entries[0].Level != zap.InfoLevel || entries[0].ContextMap()["status_code"] != codes.OK.String() {
+		t.Fatal("client stream should be logged after receiving the response", entries)
+	}
+
+	// Header 出错时结束并只打印一次
+	cs = newStream("/test/HeaderError", &fakeClientStream{headerErr: status.Error(codes.Unavailable, "unavailable"), recvErr: status.Error(codes.Unavailable, "unavailable")})
+	if _, err := cs.Header(); err == nil {
+		t.Fatal("header should return error")
+	}
+	cs.RecvMsg(nil)
+	entries = logs.FilterField(zap.String("method", "/test/HeaderError")).AllUntimed()
+	if len(entries) != 1 || entries[0].ContextMap()["status_code"] != codes.Unavailable.String() {
+		t.Fatal("header error should be logged once", entries)
+	}
+}
+
+func TestGRPCServerSpan(t *testing.T) {
+	g := newGRPCLogger(GRPCLoggerConfig{})
+	incomingSpanID := NewSpanID()
+	md := metadata.Pairs(string(TraceIDKeyname), testW3CTraceID, TraceparentHeader, FormatTraceparent(testW3CTraceID, incomingSpanID, 1))
+	ctx, _ := g.serverContext(metadata.NewIncomingContext(context.Background(), md))
+	if spanID := CtxSpanID(ctx); spanID == "" || spanID == incomingSpanID {
+		t.Error("should generate a server span id", spanID)
+	}
+	if parentSpanID := ctxStoredParentSpanID(ctx); parentSpanID != incomingSpanID {
+		t.Error("incoming span id should be the parent span id", parentSpanID)
+	}
+}
+
+func TestGRPCServerInvalidTraceID(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+
+	// TraceIDFunc 返回的 trace id 与 metadata 中的不同时，只有 metadata 中的 trace id 不合法才记录
+	g := newGRPCLogger(GRPCLoggerConfig{TraceIDFunc: func(context.Context) string { return "custom-trace-id" }})
+	for _, mdTraceID := range []string{"valid-trace-id", "invalid trace id"} {
+		md := metadata.Pairs(string(TraceIDKeyname), mdTraceID)
+		_, logger := g.serverContext(metadata.NewIncomingContext(context.Background(), md))
+		logger.Info(mdTraceID)
+	}
+	entries := logs.AllUntimed()
+	if _, exists := entries[0].ContextMap()[string(InvalidTraceIDKeyname)]; exists {
+		t.Error("valid metadata trace id should not be recorded", entries[0].ContextMap())
+	}
+	if entries[1].ContextMap()[string(InvalidTraceIDKeyname)] != "invalid trace id" {
+		t.Error("invalid metadata trace id should be recorded", entries[1].ContextMap())
+	}
+}
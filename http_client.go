@@ -0,0 +1,147 @@
+// http client 的 RoundTripper ，传递 trace id 并打印请求日志
+
+package logging
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var (
+	// http client 指标的 label ， path 只在设置 RouteFunc 时记录，避免请求路径中的 id 等参数导致 label 数量无限增长
+	promHTTPClientLabels = []string{
+		"status_code",
+		"host",
+		"path",
+		"method",
+	}
+	promHTTPClientReqCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "client_req_count",
+			Help:      "http client request count",
+		}, promHTTPClientLabels,
+	)
+	promHTTPClientReqLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Name:      "client_req_latency",
+			Help:      "http client request latency in seconds",
+		}, promHTTPClientLabels,
+	)
+)
+
+// HTTPClientLoggerConfig HTTPClientLogger 支持的配置项字段定义
+type HTTPClientLoggerConfig struct {
+	// Base 实际发送请求的 RoundTripper
+	// Optional. Default value is http.DefaultTransport
+	Base http.RoundTripper
+	// 慢请求时间阈值 请求处理时间超过该值则使用 Warn 级别打印日志
+	// Optional. Default value is 3s
+	SlowThreshold time.Duration
+	// RouteFunc 返回请求的路由模板作为 prometheus 指标的 path label ，如 /users/:id
+	// 不要直接返回 req.URL.Path ，路径中的参数会导致 label 数量无限增长
+	// Optional. Default value is nil, path label is empty
+	RouteFunc func(req *http.Request) string
+}
+
+// HTTPClientLogger 以默认配置包装 base 生成 http client 的 RoundTripper
+// http.Client{Transport: logging.HTTPClientLogger(nil)}
+func HTTPClientLogger(base http.RoundTripper) http.RoundTripper {
+	return HTTPClientLoggerWithConfig(HTTPClientLoggerConfig{Base: base})
+}
+
+// HTTPClientLoggerWithConfig 根据配置信息生成 http client 的 RoundTripper
+// 发送请求时会从 req.Context() 中获取 trace id 设置到请求 header 中，请求完成后打印请求日志
+// 日志级别与 GinLoggerWithConfig 规则相同：请求出错或 500 以上为 Error ， 400-500 为 Warn ， 慢请求为 Warn ，其他为 Info
+func HTTPClientLoggerWithConfig(conf HTTPClientLoggerConfig) http.RoundTripper {
+	if conf.Base == nil {
+		conf.Base = http.DefaultTransport
+	}
+	if conf.SlowThreshold.Seconds() <= 0 {
+		conf.SlowThreshold = defaultGinSlowThreshold
+	}
+	return &httpClientTransport{conf: conf}
+}
+
+type httpClientTransport struct {
+	conf HTTPClientLoggerConfig
+}
+
+// RoundTrip 实现 http.RoundTripper 接口
+func (t *httpClientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	ctx := req.Context()
+
+	// 保证 trace id 只生成一次，日志和请求 header 中使用同一个 trace id
+	traceID := CtxTraceID(ctx)
+	if ctxStoredTraceID(ctx) == "" {
+		ctx = context.WithValue(ctx, TraceIDKeyname, traceID)
+	}
+	var logger *zap.Logger
+	if ctxLogger, ok := ctxStoredLogger(ctx); ok {
+		logger = ctxLogger.Named("http_client")
+	} else {
+		_, logger = NewCtxLogger(ctx, CloneLogger("http_client"), traceID)
+	}
+
+	// RoundTripper 不能修改原始请求，设置 header 前先复制
+	req = req.Clone(req.Context())
+	req.Header.Set(string(TraceIDKeyname), traceID)
+	if traceparent := CtxTraceparent(ctx); traceparent != "" {
+		req.Header.Set(TraceparentHeader, traceparent)
+		if tracestate := CtxTracestate(ctx); tracestate != "" {
+			req.Header.Set(TracestateHeader, tracestate)
+		}
+	}
+
+	rsp, err := t.conf.Base.RoundTrip(req)
+
+	latency := time.Since(start).Seconds()
+	statusCode := 0
+	if rsp != nil {
+		statusCode = rsp.StatusCode
+	}
+	logger = logger.With(
+		zap.String("method", req.Method),
+		zap.String("url", req.URL.Redacted()),
+		zap.String("host", req.URL.Host),
+		zap.Int("status_code", statusCode),
+		zap.Float64("latency_seconds", latency),
+	)
+	msg := fmt.Sprintf("%s|%s|%d|%f", req.Method, req.URL.Redacted(), statusCode, latency)
+
+	// 打印请求日志，根据状态码确定日志打印级别
+	log := logger.Info
+	if err != nil {
+		logger = logger.With(zap.Error(err))
+		log = logger.Error
+	} else if statusCode >= http.StatusInternalServerError {
+		log = logger.Error
+	} else if statusCode >= http.StatusBadRequest {
+		log = logger.Warn
+	}
+	// 慢请求使用 Warn 记录
+	if latency > t.conf.SlowThreshold.Seconds() {
+		logger.Warn(msg+" hit slow request.", zap.Float64("slow_threshold", t.conf.SlowThreshold.Seconds()))
+	} else {
+		log(msg)
+	}
+
+	// update prometheus info
+	route := ""
+	if t.conf.RouteFunc != nil {
+		route = t.conf.RouteFunc(req)
+	}
+	labels := []string{fmt.Sprint(statusCode), req.URL.Host, route, req.Method}
+	promHTTPClientReqCount.WithLabelValues(labels...).Inc()
+	promHTTPClientReqLatency.WithLabelValues(labels...).Observe(latency)
+
+	return rsp, err
+}
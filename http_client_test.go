@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestHTTPClientLogger(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+
+	var gotTraceID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTraceID = r.Header.Get(string(TraceIDKeyname))
+		if r.URL.Path == "/err" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: HTTPClientLogger(nil)}
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("test"), "http-client-trace-id")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/ok", nil)
+	rsp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if gotTraceID != "http-client-trace-id" {
+		t.Error("trace id not propagated", gotTraceID)
+	}
+	if req.Header.Get(string(TraceIDKeyname)) != "" {
+		t.Error("origin request should not be modified")
+	}
+
+	// 没有 trace id 时日志和 header 中使用同一个生成的 trace id
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/err", nil)
+	rsp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatal("invalid entries count", len(entries))
+	}
+	if entries[0].Level != zap.InfoLevel || entries[0].ContextMap()[string(TraceIDKeyname)] != "http-client-trace-id" {
+		t.Error("invalid ok entry", entries[0])
+	}
+	if entries[1].Level != zap.ErrorLevel || entries[1].ContextMap()[string(TraceIDKeyname)] != gotTraceID {
+		t.Error("invalid err entry", entries[1])
+	}
+}
+
+func TestHTTPClientLoggerRoute(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	// 默认不记录请求路径
+	client := &http.Client{Transport: HTTPClientLogger(nil)}
+	for _, path := range []string{"/users/1", "/users/2"} {
+		rsp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+	}
+	if n := testutil.ToFloat64(promHTTPClientReqCount.WithLabelValues("200", host, "", http.MethodGet)); n != 2 {
+		t.Error("requests should be counted by host", n)
+	}
+
+	client = &http.Client{Transport: HTTPClientLoggerWithConfig(HTTPClientLoggerConfig{
+		RouteFunc: func(req *http.Request) string { return "/users/:id" },
+	})}
+	rsp, err := client.Get(srv.URL + "/users/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if n := testutil.ToFloat64(promHTTPClientReqCount.WithLabelValues("200", host, "/users/:id", http.MethodGet)); n != 1 {
+		t.Error("requests should be counted by route", n)
+	}
+}
+
+type errRoundTripper struct{}
+
+func (errRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("round trip error")
+}
+
+func TestHTTPClientLoggerError(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+
+	client := &http.Client{Transport: HTTPClientLogger(errRoundTripper{})}
+	if _, err := client.Get("http://localhost/error"); err == nil {
+		t.Fatal("should return error")
+	}
+	entries := logs.FilterLevelExact(zap.ErrorLevel).AllUntimed()
+	if len(entries) != 1 || entries[0].ContextMap()["error"] != "round trip error" {
+		t.Error("invalid error entry", entries)
+	}
+}
@@ -0,0 +1,303 @@
+// AtomicLevel http 服务
+// 提供查询和修改日志级别、按名称设置日志级别、按 trace id 开启 debug 日志的接口
+// 支持 basic auth 、 bearer token 、 TLS/mTLS 和 IP 白名单，修改操作会记录审计日志
+
+package logging
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// AtomicLevelServerOption AtomicLevel server 相关配置
+type AtomicLevelServerOption struct {
+	Addr         string   `json:"addr" yaml:"addr" toml:"addr"`                               // http 动态修改日志级别服务运行地址
+	Path         string   `json:"path" yaml:"path" toml:"path"`                               // 设置 url path ，可选
+	Username     string   `json:"username" yaml:"username" toml:"username"`                   // 请求时设置 basic auth 认证的用户名，可选
+	Password     string   `json:"password" yaml:"password" toml:"password"`                   // 请求时设置 basic auth 认证的密码，可选，需要与 username 同时设置
+	BearerToken  string   `json:"bearer_token" yaml:"bearer_token" toml:"bearer_token"`       // 请求时设置 Authorization: Bearer 认证的 token ，可选，与 basic auth 同时开启时满足其一即可
+	TLSCertFile  string   `json:"tls_cert_file" yaml:"tls_cert_file" toml:"tls_cert_file"`    // TLS 证书文件，可选，需要与 TLSKeyFile 同时设置
+	TLSKeyFile   string   `json:"tls_key_file" yaml:"tls_key_file" toml:"tls_key_file"`       // TLS 私钥文件，可选
+	ClientCAFile string   `json:"client_ca_file" yaml:"client_ca_file" toml:"client_ca_file"` // 校验客户端证书的 CA 文件，可选，设置后要求客户端证书 (mTLS) ，需要开启 TLS
+	AllowIPs     []string `json:"allow_ips" yaml:"allow_ips" toml:"allow_ips"`                // 允许访问的 IP 或 CIDR ，可选，为空时不限制
+}
+
+// validateAuth 校验认证配置， basic auth 的用户名和密码只设置一个时返回 error
+func (o AtomicLevelServerOption) validateAuth() error {
+	if (o.Username == "") != (o.Password == "") {
+		return errors.New("atomic level server username and password must be set together")
+	}
+	return nil
+}
+
+// validateTLS 校验 TLS 配置，证书和私钥只设置一个或未开启 TLS 时设置了 ClientCAFile 返回 error
+func (o AtomicLevelServerOption) validateTLS() error {
+	if (o.TLSCertFile == "") != (o.TLSKeyFile == "") {
+		return errors.New("atomic level server tls_cert_file and tls_key_file must be set together")
+	}
+	if o.ClientCAFile != "" && o.TLSCertFile == "" {
+		return errors.New("atomic level server client_ca_file requires tls_cert_file and tls_key_file")
+	}
+	return nil
+}
+
+var (
+	// NewLogger 启动的 AtomicLevel 服务， key 为监听地址
+	atomicLevelServers      = map[string]*sharedAtomicLevelServer{}
+	atomicLevelServersMutex sync.Mutex
+)
+
+// sharedAtomicLevelServer 多个 logger 共享的 AtomicLevel 服务
+type sharedAtomicLevelServer struct {
+	server  *AtomicLevelServer
+	options AtomicLevelServerOption
+	ring    *RingCore
+	refs    int
+}
+
+// AtomicLevelServer 运行中的 AtomicLevel http 服务
+type AtomicLevelServer struct {
+	server   *http.Server
+	listener net.Listener
+	done     chan struct{}
+	err      error
+}
+
+// Addr 返回服务监听的地址
+func (s *AtomicLevelServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Shutdown 优雅关闭服务，等待处理中的请求完成
+func (s *AtomicLevelServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// Done 服务停止后关闭的 channel
+func (s *AtomicLevelServer) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err 服务停止后返回服务异常退出的错误，调用 Shutdown 正常关闭时返回 nil
+func (s *AtomicLevelServer) Err() error {
+	<-s.done
+	return s.err
+}
+
+// StartAtomicLevelServer 启动修改 level 的 http 服务，监听失败时返回 error ，服务运行后的错误会打印日志并通过 Err 返回
+// 服务提供 AdminHandler 的全部接口， options.Path 不为空时挂载在该路径下
+// curl -X GET http://host:port
+// curl -X PUT http://host:port -d '{"level":"info"}'
+// curl -X PUT http://host:port -d '{"level":"debug","ttl":"10m"}'
+func StartAtomicLevelServer(level zap.AtomicLevel, options AtomicLevelServerOption) (*AtomicLevelServer, error) {
+	return startAdminServer(AdminOption{AtomicLevelServerOption: options, Level: &level}, CloneLogger("atomiclevel"))
+}
+
+// startAdminServer 启动日志管理接口的 http 服务，服务的运行信息使用 logger 打印
+// NewLogger 在包初始化时启动服务，全局 logger 还没有创建，不能使用全局方法打印日志
+func startAdminServer(option AdminOption, logger *zap.Logger) (*AtomicLevelServer, error) {
+	options := option.AtomicLevelServerOption
+	if err := options.validateTLS(); err != nil {
+		return nil, err
+	}
+	handler, err := AdminHandler(option)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Addr: options.Addr, Handler: stripPathPrefix(options.Path, handler)}
+	useTLS := options.TLSCertFile != "" && options.TLSKeyFile != ""
+	if useTLS && options.ClientCAFile != "" {
+		ca, err := os.ReadFile(options.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client ca file error: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid client ca file: %s", options.ClientCAFile)
+		}
+		server.TLSConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	}
+
+	listener, err := net.Listen("tcp", options.Addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &AtomicLevelServer{server: server, listener: listener, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		var err error
+		if useTLS {
+			err = server.ServeTLS(listener, options.TLSCertFile, options.TLSKeyFile)
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.err = err
+			logger.Error("logging AtomicLevel server serve error", zap.String("addr", options.Addr), zap.Error(err))
+		}
+	}()
+	logger.Debug("Running AtomicLevel HTTP server on " + listener.Addr().String() + options.Path)
+	return s, nil
+}
+
+// startSharedAtomicLevelServer NewLogger 启动 AtomicLevel 服务，相同地址的服务运行中时不重复启动
+// 服务使用默认 logger 的 atomic level ，即最近一次 NewLogger 设置的日志级别
+// ring 不为 nil 时 /logs 接口查询其中的最近日志
+// 相同地址的服务运行中但路径、认证、 TLS 、 IP 白名单配置或 ring 不同时返回 error
+func startSharedAtomicLevelServer(options AtomicLevelServerOption, ring *RingCore, logger *zap.Logger) (*AtomicLevelServer, error) {
+	atomicLevelServersMutex.Lock()
+	defer atomicLevelServersMutex.Unlock()
+	if shared, exists := atomicLevelServers[options.Addr]; exists {
+		select {
+		case <-shared.server.Done():
+		default:
+			if !sameAtomicLevelServerOption(shared.options, options) || shared.ring != ring {
+				return nil, fmt.Errorf("atomic level server on %s is already running with different options", options.Addr)
+			}
+			shared.refs++
+			return shared.server, nil
+		}
+	}
+	admin := AdminOption{AtomicLevelServerOption: options}
+	if ring != nil {
+		admin.RecentLogs = ring
+	}
+	server, err := startAdminServer(admin, logger)
+	if err != nil {
+		return nil, err
+	}
+	atomicLevelServers[options.Addr] = &sharedAtomicLevelServer{server: server, options: options, ring: ring, refs: 1}
+	return server, nil
+}
+
+// sameAtomicLevelServerOption 两个配置是否相同， AllowIPs 为 nil 和空列表时相同
+func sameAtomicLevelServerOption(a, b AtomicLevelServerOption) bool {
+	allowA, allowB := a.AllowIPs, b.AllowIPs
+	a.AllowIPs, b.AllowIPs = nil, nil
+	return reflect.DeepEqual(a, b) && slices.Equal(allowA, allowB)
+}
+
+// releaseSharedAtomicLevelServer 释放 startSharedAtomicLevelServer 返回的服务，没有使用者时停止服务
+func releaseSharedAtomicLevelServer(ctx context.Context, addr string, server *AtomicLevelServer) error {
+	atomicLevelServersMutex.Lock()
+	shared, exists := atomicLevelServers[addr]
+	if exists && shared.server == server {
+		shared.refs--
+		if shared.refs > 0 {
+			atomicLevelServersMutex.Unlock()
+			return nil
+		}
+		delete(atomicLevelServers, addr)
+	}
+	atomicLevelServersMutex.Unlock()
+	return server.Shutdown(ctx)
+}
+
+// authenticate 校验请求的认证信息，返回请求的用户，没有配置认证时允许所有请求
+func authenticate(r *http.Request, options AtomicLevelServerOption) (string, bool) {
+	user := "anonymous"
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		user = "cert:" + r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	basicEnabled := options.Username != ""
+	if !basicEnabled && options.BearerToken == "" {
+		return user, true
+	}
+	if basicEnabled {
+		if username, password, ok := r.BasicAuth(); ok &&
+			constantTimeEqual(username, options.Username) &&
+			constantTimeEqual(password, options.Password) {
+			return username, true
+		}
+	}
+	if options.BearerToken != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && constantTimeEqual(token, options.BearerToken) {
+			return "bearer", true
+		}
+	}
+	return user, false
+}
+
+// constantTimeEqual 使用固定时间比较字符串，避免时序攻击
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// parseAllowIPs 解析 IP 白名单，支持 IP 和 CIDR
+func parseAllowIPs(allowIPs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(allowIPs))
+	for _, item := range allowIPs {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allow ip: %s", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allow ip: %w", err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ipAllowed remoteAddr 是否在白名单中，白名单为空时允许所有 IP
+// 只使用连接的地址判断，不信任 X-Forwarded-For 等可以伪造的 header
+func ipAllowed(allowNets []*net.IPNet, remoteAddr string) bool {
+	if len(allowNets) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range allowNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusResponseWriter 记录响应状态码
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader 记录状态码
+func (w *statusResponseWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush 支持 SSE 等流式响应
+func (w *statusResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestStartAtomicLevelServer(t *testing.T) {
+	restoreGlobalLogger(t)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := ReloadLogger(Options{OutputPaths: []string{path}}); err != nil {
+		t.Fatal(err)
+	}
+
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	server, err := StartAtomicLevelServer(level, AtomicLevelServerOption{
+		Addr:        "127.0.0.1:0",
+		Username:    "admin",
+		Password:    "secret",
+		BearerToken: "token",
+		AllowIPs:    []string{"127.0.0.0/8", "::1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + server.Addr().String()
+	do := func(method string, auth func(*http.Request), body string) int {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		if auth != nil {
+			auth(req)
+		}
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+		return rsp.StatusCode
+	}
+	basic := func(username, password string) func(*http.Request) {
+		return func(req *http.Request) { req.SetBasicAuth(username, password) }
+	}
+	bearer := func(token string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	if code := do(http.MethodGet, nil, ""); code != http.StatusUnauthorized {
+		t.Fatal("request without auth should be unauthorized", code)
+	}
+	if code := do(http.MethodPut, basic("admin", "wrong"), `{"level":"debug"}`); code != http.StatusUnauthorized {
+		t.Fatal("wrong password should be unauthorized", code)
+	}
+	if code := do(http.MethodPut, bearer("wrong"), `{"level":"debug"}`); code != http.StatusUnauthorized {
+		t.Fatal("wrong token should be unauthorized", code)
+	}
+	if level.Level() != zap.InfoLevel {
+		t.Fatal("unauthorized request should not change level")
+	}
+	if code := do(http.MethodPut, basic("admin", "secret"), `{"level":"debug"}`); code != http.StatusOK || level.Level() != zap.DebugLevel {
+		t.Fatal("basic auth request should change level", code)
+	}
+	if code := do(http.MethodPut, bearer("token"), `{"level":"warn"}`); code != http.StatusOK || level.Level() != zap.WarnLevel {
+		t.Fatal("bearer token request should change level", code)
+	}
+
+	content := readLogFile(t, path)
+	if !strings.Contains(content, `"user":"admin"`) || !strings.Contains(content, `"from":"info"`) || !strings.Contains(content, `"to":"debug"`) {
+		t.Fatal("audit log should record user and level change:", content)
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Err(); err != nil {
+		t.Fatal("shutdown server should not return error", err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Fatal("server should be shut down")
+	}
+}
+
+func TestAtomicLevelServerAllowIPs(t *testing.T) {
+	if _, err := StartAtomicLevelServer(zap.NewAtomicLevel(), AtomicLevelServerOption{Addr: "127.0.0.1:0", AllowIPs: []string{"x"}}); err == nil {
+		t.Fatal("invalid allow ip should return error")
+	}
+	server, err := StartAtomicLevelServer(zap.NewAtomicLevel(), AtomicLevelServerOption{Addr: "127.0.0.1:0", AllowIPs: []string{"10.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown(context.Background())
+	rsp, err := http.Get("http://" + server.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusForbidden {
+		t.Fatal("ip not in allow list should be forbidden", rsp.StatusCode)
+	}
+}
+
+func TestAtomicLevelServerOptionError(t *testing.T) {
+	cases := []AtomicLevelServerOption{
+		{Addr: "127.0.0.1:0", Username: "admin"},
+		{Addr: "127.0.0.1:0", Password: "secret"},
+		{Addr: "127.0.0.1:0", TLSCertFile: "cert.pem"},
+		{Addr: "127.0.0.1:0", ClientCAFile: "ca.pem"},
+	}
+	for _, options := range cases {
+		if server, err := StartAtomicLevelServer(zap.NewAtomicLevel(), options); err == nil {
+			server.Shutdown(context.Background())
+			t.Errorf("invalid options should return error: %+v", options)
+		}
+	}
+}
+
+// TestInitAtomicLevelServer 设置 ATOMIC_LEVEL_ADDR 后在子进程中初始化包，包初始化时启动 AtomicLevel 服务不能 panic
+func TestInitAtomicLevelServer(t *testing.T) {
+	if os.Getenv("LOGGING_TEST_INIT_ATOMIC_LEVEL_SERVER") == "1" {
+		atomicLevelServersMutex.Lock()
+		defer atomicLevelServersMutex.Unlock()
+		if _, exists := atomicLevelServers[os.Getenv(AtomicLevelAddrEnvKey)]; !exists || logger == nil {
+			t.Fatal("init should start the atomic level server")
+		}
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestInitAtomicLevelServer$")
+	cmd.Env = append(os.Environ(), AtomicLevelAddrEnvKey+"=127.0.0.1:0", "LOGGING_TEST_INIT_ATOMIC_LEVEL_SERVER=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatal("init with atomic level server failed", err, string(out))
+	}
+}
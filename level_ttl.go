@@ -0,0 +1,148 @@
+// 临时修改日志级别
+// 修改日志级别时可以指定有效时长，到期后自动恢复为修改前的日志级别
+
+package logging
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	// levelTTLs 存在临时日志级别的 AtomicLevel 对应的状态，恢复后删除
+	levelTTLs      = map[zap.AtomicLevel]*levelTTL{}
+	levelTTLsMutex sync.Mutex
+
+	// 临时日志级别使用的时钟，测试时替换
+	levelTTLNow       = time.Now
+	levelTTLAfterFunc = func(d time.Duration, f func()) (stop func() bool) {
+		return time.AfterFunc(d, f).Stop
+	}
+)
+
+// levelTTL AtomicLevel 的临时日志级别状态
+type levelTTL struct {
+	stop      func() bool
+	restore   zapcore.Level // 到期后恢复的日志级别
+	expiresAt time.Time     // 到期时间
+}
+
+// setLevelTTL 设置 level 的日志级别， ttl 大于 0 时到期后恢复为第一次临时修改前的日志级别，否则取消待恢复的日志级别
+func setLevelTTL(level zap.AtomicLevel, lvl zapcore.Level, ttl time.Duration) {
+	levelTTLsMutex.Lock()
+	defer levelTTLsMutex.Unlock()
+	t, exists := levelTTLs[level]
+	if exists {
+		t.stop()
+	}
+	if ttl <= 0 {
+		delete(levelTTLs, level)
+		level.SetLevel(lvl)
+		return
+	}
+	// 已经有临时日志级别时保留最初的日志级别
+	if !exists {
+		t = &levelTTL{restore: level.Level()}
+		levelTTLs[level] = t
+	}
+	t.expiresAt = levelTTLNow().Add(ttl)
+	level.SetLevel(lvl)
+
+	t.stop = levelTTLAfterFunc(ttl, func() {
+		levelTTLsMutex.Lock()
+		// 已经被新的设置替换或取消
+		if levelTTLs[level] != t || levelTTLNow().Before(t.expiresAt) {
+			levelTTLsMutex.Unlock()
+			return
+		}
+		delete(levelTTLs, level)
+		level.SetLevel(t.restore)
+		levelTTLsMutex.Unlock()
+		Warn(nil, "Restore logging atomicLevel after ttl expired",
+			zap.String("level", t.restore.String()),
+			zap.String("temporary_level", lvl.String()),
+			zap.Duration("ttl", ttl),
+		)
+	})
+}
+
+// levelTTLState 返回 level 的当前日志级别，存在临时日志级别时返回到期时间和恢复的日志级别
+func levelTTLState(level zap.AtomicLevel) (current zapcore.Level, expiresAt time.Time, restore zapcore.Level) {
+	levelTTLsMutex.Lock()
+	defer levelTTLsMutex.Unlock()
+	if t, exists := levelTTLs[level]; exists {
+		return level.Level(), t.expiresAt, t.restore
+	}
+	return level.Level(), time.Time{}, level.Level()
+}
+
+// levelPayload 日志级别 http 请求和响应内容
+type levelPayload struct {
+	Level        string `json:"level,omitempty"`
+	TTL          string `json:"ttl,omitempty"`           // 请求时指定的有效时长，如 10m
+	ExpiresAt    string `json:"expires_at,omitempty"`    // 响应中临时日志级别的到期时间
+	RestoreLevel string `json:"restore_level,omitempty"` // 响应中到期后恢复的日志级别
+	Errmsg       string `json:"error,omitempty"`
+}
+
+// levelHandler 查询和修改 level 的 http handler ，与 zap.AtomicLevel 的 ServeHTTP 兼容，修改时支持指定 ttl
+// curl -X GET http://host:port
+// curl -X PUT http://host:port -d '{"level":"debug","ttl":"10m"}'
+// curl -X PUT http://host:port -d 'level=debug&ttl=10m' -H 'Content-Type: application/x-www-form-urlencoded'
+func levelHandler(level zap.AtomicLevel) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := jsoniter.NewEncoder(w)
+		fail := func(code int, err error) {
+			w.WriteHeader(code)
+			enc.Encode(levelPayload{Errmsg: err.Error()})
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req levelPayload
+			if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+				req.Level, req.TTL = r.FormValue("level"), r.FormValue("ttl")
+			} else if err := jsoniter.NewDecoder(r.Body).Decode(&req); err != nil {
+				fail(http.StatusBadRequest, fmt.Errorf("malformed request body: %w", err))
+				return
+			}
+			if req.Level == "" {
+				fail(http.StatusBadRequest, fmt.Errorf("must specify logging level"))
+				return
+			}
+			var lvl zapcore.Level
+			if err := lvl.UnmarshalText([]byte(req.Level)); err != nil {
+				fail(http.StatusBadRequest, err)
+				return
+			}
+			var ttl time.Duration
+			if req.TTL != "" {
+				var err error
+				if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+					fail(http.StatusBadRequest, fmt.Errorf("invalid ttl: %s", req.TTL))
+					return
+				}
+			}
+			setLevelTTL(level, lvl, ttl)
+		default:
+			fail(http.StatusMethodNotAllowed, fmt.Errorf("only GET and PUT are supported"))
+			return
+		}
+
+		current, expiresAt, restore := levelTTLState(level)
+		rsp := levelPayload{Level: current.String()}
+		if !expiresAt.IsZero() {
+			rsp.ExpiresAt = expiresAt.Format(time.RFC3339)
+			rsp.RestoreLevel = restore.String()
+		}
+		enc.Encode(rsp)
+	})
+}
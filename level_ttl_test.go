@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeLevelTTLClock 替换临时日志级别使用的时钟，调用 advance 时执行到期的定时器
+type fakeLevelTTLClock struct {
+	now    time.Time
+	timers []*fakeLevelTTLTimer
+}
+
+type fakeLevelTTLTimer struct {
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+func useFakeLevelTTLClock(t *testing.T) *fakeLevelTTLClock {
+	clock := &fakeLevelTTLClock{now: time.Now()}
+	prevNow, prevAfterFunc := levelTTLNow, levelTTLAfterFunc
+	levelTTLNow = func() time.Time { return clock.now }
+	levelTTLAfterFunc = func(d time.Duration, f func()) func() bool {
+		timer := &fakeLevelTTLTimer{at: clock.now.Add(d), f: f}
+		clock.timers = append(clock.timers, timer)
+		return func() bool {
+			stopped := !timer.stopped
+			timer.stopped = true
+			return stopped
+		}
+	}
+	t.Cleanup(func() { levelTTLNow, levelTTLAfterFunc = prevNow, prevAfterFunc })
+	return clock
+}
+
+func (c *fakeLevelTTLClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+	for _, timer := range c.timers {
+		if !timer.stopped && !timer.at.After(c.now) {
+			timer.stopped = true
+			timer.f()
+		}
+	}
+}
+
+func TestSetLevelTTL(t *testing.T) {
+	clock := useFakeLevelTTLClock(t)
+	prev := atomicLevel.Level()
+	t.Cleanup(func() { setLevelTTL(atomicLevel, prev, 0) })
+
+	SetLevel("warn")
+	SetLevel("debug", 50*time.Millisecond)
+	// 临时日志级别未到期时再次临时修改，到期后恢复为最初的日志级别
+	SetLevel("info", 100*time.Millisecond)
+	if atomicLevel.Level() != zap.InfoLevel {
+		t.Fatal("level should be info")
+	}
+	clock.advance(60 * time.Millisecond)
+	if atomicLevel.Level() != zap.InfoLevel {
+		t.Fatal("replaced ttl should not restore level")
+	}
+	clock.advance(50 * time.Millisecond)
+	if atomicLevel.Level() != zap.WarnLevel {
+		t.Fatal("level should be restored to warn, got", atomicLevel.Level())
+	}
+	// 恢复后删除临时日志级别状态
+	if _, exists := levelTTLs[atomicLevel]; exists {
+		t.Fatal("restored level ttl should be deleted")
+	}
+
+	// 不指定 ttl 时取消待恢复的日志级别
+	SetLevel("debug", 50*time.Millisecond)
+	SetLevel("error")
+	clock.advance(80 * time.Millisecond)
+	if atomicLevel.Level() != zap.ErrorLevel {
+		t.Fatal("level should be error, got", atomicLevel.Level())
+	}
+	if _, exists := levelTTLs[atomicLevel]; exists {
+		t.Fatal("canceled level ttl should be deleted")
+	}
+}
+
+func TestSetLevelInvalid(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+	prev := atomicLevel.Level()
+	t.Cleanup(func() { setLevelTTL(atomicLevel, prev, 0) })
+
+	SetLevel("warn")
+	SetLevel("x", time.Minute)
+	if atomicLevel.Level() != zap.WarnLevel {
+		t.Fatal("invalid level should not change the level", atomicLevel.Level())
+	}
+	// 日志级别不合法时不打印修改日志级别的日志
+	entries := logs.AllUntimed()
+	if len(entries) != 2 || entries[0].Message != "Set logging atomicLevel warn" || entries[1].Level != zap.ErrorLevel {
+		t.Fatal("invalid level should only log an error", entries)
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	handler := levelHandler(level)
+	do := func(method, contentType, body string) (int, levelPayload) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		handler.ServeHTTP(w, req)
+		var rsp levelPayload
+		if err := jsoniter.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, rsp
+	}
+
+	if code, rsp := do(http.MethodPut, "", `{"level":"debug","ttl":"1h"}`); code != http.StatusOK || rsp.Level != "debug" || rsp.RestoreLevel != "info" {
+		t.Fatal("put level with ttl failed", code, rsp)
+	}
+	code, rsp := do(http.MethodGet, "", "")
+	if code != http.StatusOK || rsp.Level != "debug" || rsp.RestoreLevel != "info" {
+		t.Fatal("get level failed", code, rsp)
+	}
+	if expiresAt, err := time.Parse(time.RFC3339, rsp.ExpiresAt); err != nil || time.Until(expiresAt) < 59*time.Minute {
+		t.Fatal("invalid expires_at", rsp.ExpiresAt, err)
+	}
+	if code, rsp := do(http.MethodPut, "application/x-www-form-urlencoded", "level=warn"); code != http.StatusOK || rsp.Level != "warn" || rsp.ExpiresAt != "" {
+		t.Fatal("put level without ttl should cancel expiry", code, rsp)
+	}
+	if code, _ := do(http.MethodPut, "", `{"level":"debug","ttl":"-1m"}`); code != http.StatusBadRequest {
+		t.Fatal("invalid ttl should return 400")
+	}
+	if code, _ := do(http.MethodPut, "", `{"ttl":"1m"}`); code != http.StatusBadRequest {
+		t.Fatal("missing level should return 400")
+	}
+	if level.Level() != zap.WarnLevel {
+		t.Fatal("level should be warn")
+	}
+}
@@ -0,0 +1,133 @@
+// logger 生命周期
+// LoggerHandle 负责在程序退出前写入缓存的日志、关闭日志输出、 flush sentry 和停止 AtomicLevel 服务
+
+package logging
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// ctx 没有设置 deadline 时 flush sentry 的超时时间
+	defaultSentryFlushTimeout = 2 * time.Second
+)
+
+// LoggerHandle 管理 NewLoggerWithHandle 创建的 logger 的日志输出、 sentry client 和 AtomicLevel 服务
+type LoggerHandle struct {
+	logger       *zap.Logger
+	errorOutput  zapcore.WriteSyncer
+	closer       func()
+	sentryClient *sentry.Client
+	server       *AtomicLevelServer
+	serverAddr   string
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewLoggerWithHandle 创建 logger 并返回管理它生命周期的 handle
+// logger, handle, err := logging.NewLoggerWithHandle(options)
+// defer handle.Close(context.Background())
+func NewLoggerWithHandle(options Options) (*zap.Logger, *LoggerHandle, error) {
+	return newLogger(options)
+}
+
+// Flush 写入缓存的日志并 flush sentry ，标准输出不支持 Sync 的错误会被忽略
+func (h *LoggerHandle) Flush(ctx context.Context) error {
+	var errs []error
+	if err := h.logger.Sync(); err != nil && !isIgnorableSyncError(err) {
+		errs = append(errs, err)
+	}
+	if h.sentryClient != nil {
+		timeout := defaultSentryFlushTimeout
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+		if !h.sentryClient.Flush(timeout) {
+			errs = append(errs, errors.New("sentry flush timeout"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close 写入缓存的日志、 flush sentry 、关闭日志输出并停止 AtomicLevel 服务，多次调用只会关闭一次
+// 相同地址的 AtomicLevel 服务被多个 logger 共享时，最后一个使用者关闭时才会停止服务
+func (h *LoggerHandle) Close(ctx context.Context) error {
+	h.closeOnce.Do(func() {
+		errs := []error{h.Flush(ctx)}
+		if h.closer != nil {
+			h.closer()
+		}
+		if h.server != nil {
+			errs = append(errs, releaseSharedAtomicLevelServer(ctx, h.serverAddr, h.server))
+		}
+		h.closeErr = errors.Join(errs...)
+	})
+	return h.closeErr
+}
+
+// CloseOnSignal 收到信号时在 timeout 内调用 Close ，默认监听 SIGTERM 和 SIGINT ，返回停止监听的函数
+// 关闭后停止监听，不会重新发送信号，应用自己使用 signal.Notify 监听这些信号并负责退出进程
+// logger 已经关闭，关闭时的错误使用标准库 log 输出到 stderr
+func (h *LoggerHandle) CloseOnSignal(timeout time.Duration, signals ...os.Signal) func() {
+	return h.closeOnSignal(timeout, false, signals)
+}
+
+// CloseOnSignalThenResend 与 CloseOnSignal 相同，关闭后将信号重新发送给当前进程，由信号的默认行为（如退出进程）处理
+// 注意：应用中其他 signal.Notify 的监听者会再次收到该信号，很多服务会将第二次收到的信号视为强制退出，
+// 只在应用没有自己监听这些信号时使用
+func (h *LoggerHandle) CloseOnSignalThenResend(timeout time.Duration, signals ...os.Signal) func() {
+	return h.closeOnSignal(timeout, true, signals)
+}
+
+func (h *LoggerHandle) closeOnSignal(timeout time.Duration, resend bool, signals []os.Signal) func() {
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGTERM, syscall.SIGINT}
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, signals...)
+	done := make(chan struct{})
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+		})
+	}
+	go func() {
+		select {
+		case sig := <-sigs:
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			h.logger.Info("logging close logger on signal", zap.String("signal", sig.String()))
+			if err := h.Close(ctx); err != nil {
+				log.Printf("logging close logger on signal %s error: %v", sig, err)
+			}
+			stop()
+			if !resend {
+				return
+			}
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				p.Signal(sig)
+			}
+		case <-done:
+		}
+	}()
+	return stop
+}
+
+// isIgnorableSyncError stdout 、 stderr 为终端或管道时 Sync 返回的错误
+func isIgnorableSyncError(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY)
+}
@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestLoggerHandleClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "handle.log")
+	options := Options{
+		OutputPaths:       []string{path},
+		Async:             &AsyncOption{FlushInterval: time.Hour},
+		AtomicLevelServer: AtomicLevelServerOption{Addr: "127.0.0.1:0"},
+	}
+	logger, handle, err := NewLoggerWithHandle(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := NewLoggerWithHandle(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + handle.server.Addr().String()
+	logger.Info("handle")
+	if err := handle.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if content := readLogFile(t, path); !strings.Contains(content, "handle") {
+		t.Fatal("flush should write buffered logs", content)
+	}
+
+	if err := handle.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := handle.Close(context.Background()); err != nil {
+		t.Fatal("close twice should not return error", err)
+	}
+	rsp, err := http.Get(url)
+	if err != nil {
+		t.Fatal("shared server should keep running until the last handle is closed", err)
+	}
+	rsp.Body.Close()
+	if err := other.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Fatal("server should be stopped after all handles are closed")
+	}
+}
+
+func TestLoggerHandleCloseOnSignal(t *testing.T) {
+	for _, resend := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "signal.log")
+		logger, handle, err := NewLoggerWithHandle(Options{
+			OutputPaths: []string{path},
+			Async:       &AsyncOption{FlushInterval: time.Hour},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 应用自己的监听者，同时避免 SIGUSR1 的默认行为退出测试进程
+		received := make(chan os.Signal, 2)
+		signal.Notify(received, syscall.SIGUSR1)
+		var stop func()
+		if resend {
+			stop = handle.CloseOnSignalThenResend(time.Second, syscall.SIGUSR1)
+		} else {
+			stop = handle.CloseOnSignal(time.Second, syscall.SIGUSR1)
+		}
+		logger.Info("before signal")
+		syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+		<-received
+
+		// 只有 CloseOnSignalThenResend 会让其他监听者再次收到信号
+		select {
+		case <-received:
+			if !resend {
+				t.Fatal("CloseOnSignal should not resend the signal")
+			}
+		case <-time.After(200 * time.Millisecond):
+			if resend {
+				t.Fatal("signal should be resent after closing the logger")
+			}
+		}
+		stop()
+		signal.Stop(received)
+		content := readLogFile(t, path)
+		if !strings.Contains(content, "close logger on signal") || !strings.Contains(content, "before signal") {
+			t.Fatal("close should write buffered logs", content)
+		}
+	}
+}
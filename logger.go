@@ -10,16 +10,14 @@
 package logging
 
 import (
-	"bytes"
-	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
-	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	"go.uber.org/zap"
@@ -60,14 +58,6 @@ var (
 	rwMutex sync.RWMutex
 )
 
-// AtomicLevelServerOption AtomicLevel server 相关配置
-type AtomicLevelServerOption struct {
-	Addr     string // http 动态修改日志级别服务运行地址
-	Path     string // 设置 url path ，可选
-	Username string // 请求时设置 basic auth 认证的用户名，可选
-	Password string // 请求时设置 basic auth 认证的密码，可选，与 username 同时存在才开启 basic auth
-}
-
 // Options new logger options
 type Options struct {
 	Name              string                  // logger 名称
@@ -81,6 +71,14 @@ type Options struct {
 	EncoderConfig     *zapcore.EncoderConfig  // 配置日志字段 key 的名称
 	LumberjackSink    *LumberjackSink         // lumberjack sink 支持日志文件 rotate
 	AtomicLevelServer AtomicLevelServerOption // AtomicLevel server 相关配置
+	Redactor          *Redactor               // 日志字段脱敏器，为 nil 时不脱敏
+	Sampling          *SamplingOption         // 日志采样配置，为 nil 时使用默认配置：每秒同级别同内容的日志超过 100 条后每 100 条输出一次
+	SentryTags        map[string]string       // 上报 sentry 时添加的 tags
+	Async             *AsyncOption            // 异步写日志配置，为 nil 时同步写入日志输出
+	SyncPolicy        *SyncPolicy             // 日志输出的 Sync 策略，为 nil 时只在打印 Panic 及以上级别的日志、调用 Sync 或关闭时 Sync
+	Routes            []Route                 // 日志路由规则，满足条件的日志同时写入路由的日志输出
+	Outputs           []Output                // 每个日志输出单独设置日志格式和 EncoderConfig ，设置后忽略 OutputPaths 和 Format
+	Ring              *RingCore               // 在内存中保存最近日志，与日志输出一样经过脱敏、采样和日志级别判断
 }
 
 const (
@@ -90,8 +88,6 @@ const (
 
 // init the global logger
 func init() {
-	var err error
-
 	options := Options{
 		Name:              loggerName,
 		Level:             "debug",
@@ -107,63 +103,70 @@ func init() {
 		EncoderConfig:  &EncoderConfig,
 		LumberjackSink: nil,
 	}
-	logger, err = NewLogger(options)
+	l, handle, err := newLogger(options)
 	if err != nil {
 		log.Println(err)
+		return
 	}
+	// 全局 logger 使用可以替换的 core ，重新加载配置后由它派生的 logger 也会使用新的 core
+	logger = rootCore.wrap(l, handle.errorOutput, handle.closer)
+	setRootHelper(logger)
+	setGlobalOptions(options)
 }
 
 // NewLogger return a zap Logger instance
 func NewLogger(options Options) (*zap.Logger, error) {
-	cfg := zap.Config{}
+	logger, _, err := newLogger(options)
+	return logger, err
+}
+
+// newLogger 创建 logger ，返回管理它生命周期的 handle
+func newLogger(options Options) (*zap.Logger, *LoggerHandle, error) {
 	// 设置日志级别
+	var level zap.AtomicLevel
 	lvl := strings.ToLower(options.Level)
-	if _, exists := AtomicLevelMap[lvl]; !exists {
-		cfg.Level = atomicLevel
-	} else {
-		cfg.Level = AtomicLevelMap[lvl]
-		atomicLevel = cfg.Level
-	}
-	// 设置 encoding 默认为 json
-	if strings.ToLower(options.Format) == "console" {
-		cfg.Encoding = "console"
+	if _, exists := ZapcoreLevelMap[lvl]; !exists {
+		level = atomicLevel
 	} else {
-		cfg.Encoding = "json"
+		level = zap.NewAtomicLevelAt(ZapcoreLevelMap[lvl])
+		atomicLevel = level
 	}
-	// 设置 output 没有传参默认全部输出到 stderr
-	if len(options.OutputPaths) == 0 {
-		cfg.OutputPaths = outPaths
-		cfg.ErrorOutputPaths = outPaths
-	} else {
-		cfg.OutputPaths = options.OutputPaths
-		cfg.ErrorOutputPaths = options.OutputPaths
+	logger, errorOutput, closer, err := buildLoggerWithErrorOutput(options, level)
+	if err != nil {
+		return nil, nil, err
 	}
-	// 设置 InitialFields 没有传参使用默认字段
-	// 传了就添加到现有的初始化字段中
-	if len(options.InitialFields) > 0 {
-		for k, v := range options.InitialFields {
-			initialFields[k] = v
+	handle := &LoggerHandle{logger: logger, errorOutput: errorOutput, closer: closer, sentryClient: options.SentryClient}
+	if addr := options.AtomicLevelServer.Addr; addr != "" {
+		// 包初始化时全局 logger 还没有创建，使用正在创建的 logger 打印日志
+		server, err := startSharedAtomicLevelServer(options.AtomicLevelServer, options.Ring, logger)
+		if err != nil {
+			logger.Error("logging NewLogger start AtomicLevel server error", zap.Error(err))
+		} else {
+			handle.server, handle.serverAddr = server, addr
 		}
 	}
-	cfg.InitialFields = initialFields
-	// 设置 disablecaller
-	cfg.DisableCaller = options.DisableCaller
-	// 设置 disablestacktrace
-	cfg.DisableStacktrace = options.DisableStacktrace
+	return logger, handle, nil
+}
 
-	// 设置 encoderConfig
-	if options.EncoderConfig == nil {
-		cfg.EncoderConfig = EncoderConfig
-	} else {
-		cfg.EncoderConfig = *options.EncoderConfig
-	}
+// buildLogger 根据 options 使用 level 创建 logger ，返回关闭日志输出的函数
+func buildLogger(options Options, level zap.AtomicLevel) (*zap.Logger, func(), error) {
+	l, _, closer, err := buildLoggerWithErrorOutput(options, level)
+	return l, closer, err
+}
 
-	// Sampling 实现了日志的流控功能，或者叫采样配置，主要有两个配置参数， Initial 和 Thereafter ，实现的效果是在 1s 的时间单位内，如果某个日志级别下同样内容的日志输出数量超过了 Initial 的数量，那么超过之后，每隔 Thereafter 的数量，才会再输出一次。是一个对日志输出的保护功能。
-	cfg.Sampling = &zap.SamplingConfig{
-		Initial:    100,
-		Thereafter: 100,
+// buildLoggerWithErrorOutput 与 buildLogger 相同，同时返回 logger 的 ErrorOutput ，全局 logger 重新加载时需要替换它
+func buildLoggerWithErrorOutput(options Options, level zap.AtomicLevel) (*zap.Logger, zapcore.WriteSyncer, func(), error) {
+	// 设置 output 没有传参默认全部输出到 stderr
+	outputPaths := options.OutputPaths
+	if len(outputPaths) == 0 {
+		outputPaths = outPaths
 	}
 
+	// 设置 encoderConfig
+	encoderConfig := EncoderConfig
+	if options.EncoderConfig != nil {
+		encoderConfig = *options.EncoderConfig
+	}
 	// 注册 lumberjack sink ，支持 Outputs 指定为文件时可以使用 lumberjack 对日志文件自动 rotate
 	if options.LumberjackSink != nil {
 		if err := RegisterLumberjackSink(options.LumberjackSink); err != nil {
@@ -171,27 +174,138 @@ func NewLogger(options Options) (*zap.Logger, error) {
 		}
 	}
 
-	// 生成 logger
-	logger, err := cfg.Build()
+	// 打开日志输出，保留关闭函数以便重新加载配置后关闭旧的输出
+	var core zapcore.Core
+	var closeOut func()
+	if len(options.Outputs) > 0 {
+		// 每个日志输出使用各自的日志格式和 EncoderConfig
+		outputPaths = outputsPaths(options.Outputs)
+		var err error
+		core, closeOut, err = newOutputsCore(options.Outputs, options.Format, encoderConfig, options.Async)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	} else {
+		sink, closeSink, err := openOutput(outputPaths, options.Async)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// 设置 encoding 默认为 json ，日志级别由 namedLevelCore 按 logger 名称判断，这里允许所有级别
+		core, closeOut = zapcore.NewCore(newEncoder(options.Format, encoderConfig), sink, zap.DebugLevel), closeSink
+	}
+	errSink, closeErrOut, err := zap.Open(outputPaths...)
 	if err != nil {
-		return nil, err
+		closeOut()
+		return nil, nil, nil, err
+	}
+	closer := func() {
+		closeOut()
+		closeErrOut()
+	}
+
+	opts := []zap.Option{zap.ErrorOutput(errSink)}
+	// 设置 disablecaller
+	if !options.DisableCaller {
+		opts = append(opts, zap.AddCaller())
+	}
+	// 设置 disablestacktrace
+	if !options.DisableStacktrace {
+		opts = append(opts, zap.AddStacktrace(zap.ErrorLevel))
 	}
 
+	// 设置 InitialFields 没有传参使用默认字段
+	// 传了就添加到默认的初始化字段中
+	fields := make(map[string]interface{}, len(initialFields)+len(options.InitialFields))
+	for k, v := range initialFields {
+		fields[k] = v
+	}
+	for k, v := range options.InitialFields {
+		fields[k] = v
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	zapFields := make([]zap.Field, 0, len(keys))
+	for _, k := range keys {
+		zapFields = append(zapFields, zap.Any(k, fields[k]))
+	}
+	// 按路由规则将日志同时写入其他日志输出
+	core, closeRoutes, err := newRoutedCore(core, options.Routes, options.Format, encoderConfig, options.Async)
+	if err != nil {
+		closer()
+		return nil, nil, nil, err
+	}
+	closeMain := closer
+	closer = func() {
+		closeMain()
+		closeRoutes()
+	}
+	// 最近日志与日志输出使用相同的脱敏和采样
+	if options.Ring != nil {
+		core = zapcore.NewTee(core, options.Ring)
+	}
+	core = core.With(zapFields)
+
+	// 设置脱敏和采样，在采样之前对字段进行脱敏
+	core, err = NewSamplingCore(NewRedactCore(core, options.Redactor), options.Sampling)
+	if err != nil {
+		closer()
+		return nil, nil, nil, err
+	}
+	// 在采样之前判断日志级别，没有按名称设置级别的 logger 使用 level
+	core = newNamedLevelCore(core, level)
+
 	// 如果传了 sentryclient 则设置 sentrycore
+	outputsCore := core
 	if options.SentryClient != nil {
-		logger = SentryAttach(logger, options.SentryClient)
+		core = zapcore.NewTee(core, NewRedactCore(newDefaultSentryCore(options.SentryClient, options.SentryTags), options.Redactor))
+	}
+
+	// 设置 Sync 策略，只 Sync 日志输出，不 flush sentry
+	core, stopSync, err := newSyncPolicyCore(core, outputsCore, options.SyncPolicy)
+	if err != nil {
+		closer()
+		return nil, nil, nil, err
+	}
+	closeOutputs := closer
+	closer = func() {
+		stopSync()
+		closeOutputs()
 	}
 
 	// 设置 logger 名字，没有传参使用默认名字
-	if options.Name != "" {
-		logger = logger.Named(options.Name)
-	} else {
-		logger = logger.Named(loggerName)
+	name := options.Name
+	if name == "" {
+		name = loggerName
+	}
+	return zap.New(core, opts...).Named(name), errSink, closer, nil
+}
+
+// newEncoder 根据日志格式创建 encoder ，默认为 json
+func newEncoder(format string, encoderConfig zapcore.EncoderConfig) zapcore.Encoder {
+	if strings.ToLower(format) == "console" {
+		return zapcore.NewConsoleEncoder(encoderConfig)
+	}
+	return zapcore.NewJSONEncoder(encoderConfig)
+}
+
+// openOutput 打开日志输出，设置了 async 时异步写入，返回关闭日志输出的函数
+func openOutput(paths []string, async *AsyncOption) (zapcore.WriteSyncer, func(), error) {
+	sink, closeOut, err := zap.Open(paths...)
+	if err != nil {
+		return nil, nil, err
 	}
-	if options.AtomicLevelServer.Addr != "" {
-		runAtomicLevelServer(cfg.Level, options.AtomicLevelServer)
+	if async == nil {
+		return sink, closeOut, nil
 	}
-	return logger, nil
+	// 异步写日志，关闭时先写入队列中的日志再关闭日志输出
+	asyncSink := NewAsyncWriteSyncer(sink, *async)
+	return asyncSink, func() {
+		asyncSink.Close()
+		closeOut()
+	}, nil
 }
 
 // CloneLogger return the global logger copy which add a new name
@@ -208,6 +322,7 @@ func CloneLogger(name string, fields ...zap.Field) *zap.Logger {
 }
 
 // AttachCore add a core to zap logger
+// 添加的 core 不经过 Options.Redactor 脱敏，需要脱敏时使用 NewRedactCore 包装
 func AttachCore(l *zap.Logger, c zapcore.Core) *zap.Logger {
 	return l.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
 		return zapcore.NewTee(core, c)
@@ -223,6 +338,7 @@ func ReplaceLogger(newLogger *zap.Logger) func() {
 	prevLogger := logger
 	// 替换为新 logger
 	logger = newLogger
+	setRootHelper(newLogger)
 	return func() { ReplaceLogger(prevLogger) }
 }
 
@@ -233,9 +349,22 @@ func TextLevel() string {
 }
 
 // SetLevel 使用字符串级别设置默认 logger 的 atomic level
-func SetLevel(lvl string) {
-	Warn(nil, "Set logging atomicLevel "+lvl)
-	atomicLevel.UnmarshalText([]byte(strings.ToLower(lvl)))
+// 指定 ttl 时为临时日志级别，到期后自动恢复为临时修改前的日志级别
+// 日志级别不合法时打印 error 日志，不修改日志级别
+func SetLevel(lvl string, ttl ...time.Duration) {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(strings.ToLower(lvl))); err != nil {
+		Error(nil, "Set logging atomicLevel error", zap.String("level", lvl), zap.Error(err))
+		return
+	}
+	var d time.Duration
+	msg := "Set logging atomicLevel " + level.String()
+	if len(ttl) > 0 && ttl[0] > 0 {
+		d = ttl[0]
+		msg += " for " + d.String()
+	}
+	Warn(nil, msg)
+	setLevelTTL(atomicLevel, level, d)
 }
 
 // SentryClient 返回默认 sentry client
@@ -255,40 +384,3 @@ func ServerIP() string {
 
 	return localAddr.IP.String()
 }
-
-// 运行 atomic level server
-func runAtomicLevelServer(atomicLevel zap.AtomicLevel, options AtomicLevelServerOption) {
-	go func() {
-		// curl -X GET http://host:port
-		// curl -X PUT http://host:port -d '{"level":"info"}'
-		Debug(nil, "Running AtomicLevel HTTP server on "+options.Addr+options.Path)
-		urlPath := "/"
-		if options.Path != "" {
-			urlPath = options.Path
-		}
-
-		levelServer := http.NewServeMux()
-		levelServer.HandleFunc(urlPath, func(w http.ResponseWriter, r *http.Request) {
-			msg := fmt.Sprintf("%s %s the logger atomic level", r.RemoteAddr, r.Method)
-			_, logger := NewCtxLogger(r.Context(), CloneLogger("atomiclevel"), r.Header.Get(string(TraceIDKeyname)))
-			if r.Method == http.MethodPut {
-				b, _ := ioutil.ReadAll(r.Body)
-				msg += " to " + string(b)
-				r.Body = ioutil.NopCloser(bytes.NewBuffer(b))
-				logger.Warn(msg)
-			} else {
-				logger.Info(msg)
-			}
-			if options.Username != "" && options.Password != "" {
-				if _, _, ok := r.BasicAuth(); !ok {
-					http.Error(w, "need to basic auth", http.StatusUnauthorized)
-					return
-				}
-			}
-			atomicLevel.ServeHTTP(w, r)
-		})
-		if err := http.ListenAndServe(options.Addr, levelServer); err != nil {
-			Error(nil, "logging NewLogger levelServer ListenAndServe error:"+err.Error())
-		}
-	}()
-}
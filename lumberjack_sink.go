@@ -6,6 +6,7 @@ package logging
 
 import (
 	"net/url"
+	"sync"
 
 	"github.com/natefinch/lumberjack"
 	"go.uber.org/zap"
@@ -16,6 +17,12 @@ const (
 	LogFilename = "/tmp/logging.log"
 )
 
+var (
+	// 已注册的 lumberjack sink ， key 为 scheme
+	lumberjackSinks      = map[string]*LumberjackSink{}
+	lumberjackSinksMutex sync.Mutex
+)
+
 // LumberjackSink 将日志输出到 lumberjack 进行 rotate
 type LumberjackSink struct {
 	*lumberjack.Logger
@@ -30,14 +37,29 @@ func (LumberjackSink) Sync() error {
 // RegisterLumberjackSink 注册 lumberjack sink
 // 在 OutputPaths 中指定输出为 sink.Scheme://log_filename 即可使用
 // path url 中不指定日志文件名则使用默认的名称
-// 一个 scheme 只能对应一个文件名，相同的 scheme 注册无效，会全部写入同一个文件
+// 一个 scheme 只能对应一个文件名，相同的 scheme 再次注册时替换为新的 sink ，之后打开的日志输出使用新的 sink
 func RegisterLumberjackSink(sink *LumberjackSink) error {
-	err := zap.RegisterSink(sink.Scheme, func(*url.URL) (zap.Sink, error) {
+	lumberjackSinksMutex.Lock()
+	defer lumberjackSinksMutex.Unlock()
+	_, registered := lumberjackSinks[sink.Scheme]
+	lumberjackSinks[sink.Scheme] = sink
+	if registered {
+		return nil
+	}
+	// zap 中一个 scheme 只能注册一次，注册的函数中获取该 scheme 当前对应的 sink
+	scheme := sink.Scheme
+	err := zap.RegisterSink(scheme, func(*url.URL) (zap.Sink, error) {
+		lumberjackSinksMutex.Lock()
+		defer lumberjackSinksMutex.Unlock()
+		sink := lumberjackSinks[scheme]
 		if sink.Filename == "" {
 			sink.Filename = LogFilename
 		}
 		return sink, nil
 	})
+	if err != nil {
+		delete(lumberjackSinks, scheme)
+	}
 	return err
 }
 
@@ -0,0 +1,226 @@
+// 按 logger 名称设置日志级别
+// logger 名称为 CloneLogger 、 Named 时使用的完整名称，如 logging.gorm ，
+// 可以使用 logging.gin.* 匹配 logging.gin 及其下所有名称，没有设置的名称使用全局的 atomicLevel
+
+package logging
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// namedLevelWildcard 名称前缀匹配的后缀
+	namedLevelWildcard = ".*"
+	// noNamedLevel 没有按名称设置级别时的最低级别，高于所有日志级别
+	noNamedLevel = zapcore.FatalLevel + 1
+)
+
+var (
+	// namedLevels 全局的按名称设置的日志级别
+	namedLevels = newNamedLevelRegistry()
+)
+
+// namedLevelRegistry 保存按名称设置的日志级别
+type namedLevelRegistry struct {
+	mutex  sync.RWMutex
+	levels map[string]zapcore.Level
+	// 所有设置中最低的级别，没有设置时为 noNamedLevel
+	minLevel atomic.Int32
+}
+
+func newNamedLevelRegistry() *namedLevelRegistry {
+	r := &namedLevelRegistry{levels: map[string]zapcore.Level{}}
+	r.minLevel.Store(int32(noNamedLevel))
+	return r
+}
+
+// SetNamedLevel 设置指定名称 logger 的日志级别， name 以 .* 结尾时匹配该名称及其下所有名称
+func SetNamedLevel(name, lvl string) error {
+	level, exists := ZapcoreLevelMap[strings.ToLower(lvl)]
+	if !exists {
+		return fmt.Errorf("invalid level: %s", lvl)
+	}
+	if name == "" || name == namedLevelWildcard {
+		return fmt.Errorf("invalid logger name: %q", name)
+	}
+	Warn(nil, "Set logging named level", zap.String("name", name), zap.String("level", level.String()))
+	namedLevels.set(name, level)
+	return nil
+}
+
+// DeleteNamedLevel 删除指定名称 logger 的日志级别设置，删除后使用全局的 atomicLevel
+func DeleteNamedLevel(name string) {
+	Warn(nil, "Delete logging named level", zap.String("name", name))
+	namedLevels.delete(name)
+}
+
+// NamedLevels 返回当前按名称设置的全部日志级别
+func NamedLevels() map[string]string {
+	namedLevels.mutex.RLock()
+	defer namedLevels.mutex.RUnlock()
+	levels := make(map[string]string, len(namedLevels.levels))
+	for name, level := range namedLevels.levels {
+		levels[name] = level.String()
+	}
+	return levels
+}
+
+func (r *namedLevelRegistry) set(name string, level zapcore.Level) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.levels[name] = level
+	r.updateMinLevel()
+}
+
+func (r *namedLevelRegistry) delete(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.levels, name)
+	r.updateMinLevel()
+}
+
+// updateMinLevel 需要在持有写锁时调用
+func (r *namedLevelRegistry) updateMinLevel() {
+	min := noNamedLevel
+	for _, level := range r.levels {
+		if level < min {
+			min = level
+		}
+	}
+	r.minLevel.Store(int32(min))
+}
+
+// lookup 返回名称对应的日志级别，精确匹配优先，其次是最长的前缀匹配
+func (r *namedLevelRegistry) lookup(name string) (zapcore.Level, bool) {
+	if zapcore.Level(r.minLevel.Load()) == noNamedLevel {
+		return noNamedLevel, false
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if level, exists := r.levels[name]; exists {
+		return level, true
+	}
+	matched, found, matchedLen := noNamedLevel, false, -1
+	for pattern, level := range r.levels {
+		if !strings.HasSuffix(pattern, namedLevelWildcard) {
+			continue
+		}
+		prefix := strings.TrimSuffix(pattern, namedLevelWildcard)
+		if (name == prefix || strings.HasPrefix(name, prefix+".")) && len(prefix) > matchedLen {
+			matched, found, matchedLen = level, true, len(prefix)
+		}
+	}
+	return matched, found
+}
+
+// matchLoggerName logger 名称是否匹配 pattern
+// pattern 以 .* 结尾时匹配该名称及其下所有名称，以 *. 开头时匹配以该名称结尾的所有名称
+func matchLoggerName(pattern, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, namedLevelWildcard); ok {
+		return name == prefix || strings.HasPrefix(name, prefix+".")
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return name == suffix || strings.HasSuffix(name, "."+suffix)
+	}
+	return name == pattern
+}
+
+// namedLevelCore 根据日志的 logger 名称判断级别，没有设置的名称使用 level
+// 通过 With 添加了 forceDebugField 的 core 不判断日志级别
+type namedLevelCore struct {
+	zapcore.Core
+	level      zapcore.LevelEnabler
+	levels     *namedLevelRegistry
+	forceDebug bool
+}
+
+// newNamedLevelCore 创建按名称判断日志级别的 core ， core 本身的级别需要允许所有级别
+func newNamedLevelCore(core zapcore.Core, level zapcore.LevelEnabler) zapcore.Core {
+	return &namedLevelCore{Core: core, level: level, levels: namedLevels}
+}
+
+// Enabled zap core interface ，任意名称可能开启该级别时返回 true
+func (c *namedLevelCore) Enabled(lvl zapcore.Level) bool {
+	if c.forceDebug || c.level.Enabled(lvl) {
+		return true
+	}
+	return lvl >= zapcore.Level(c.levels.minLevel.Load())
+}
+
+// With zap core interface
+func (c *namedLevelCore) With(fields []zapcore.Field) zapcore.Core {
+	forceDebug := c.forceDebug
+	filtered := fields[:0:0]
+	for _, f := range fields {
+		if isForceDebugField(f) {
+			forceDebug = true
+			continue
+		}
+		filtered = append(filtered, f)
+	}
+	return &namedLevelCore{Core: c.Core.With(filtered), level: c.level, levels: c.levels, forceDebug: forceDebug}
+}
+
+// Check zap core interface
+func (c *namedLevelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.forceDebug {
+		return c.Core.Check(ent, ce)
+	}
+	if level, exists := c.levels.lookup(ent.LoggerName); exists {
+		if !level.Enabled(ent.Level) {
+			return ce
+		}
+	} else if !c.level.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// namedLevelsPayload 按名称设置日志级别的 http 请求和响应内容
+type namedLevelsPayload struct {
+	Name   string            `json:"name,omitempty"`
+	Level  string            `json:"level,omitempty"`
+	Names  map[string]string `json:"names,omitempty"`
+	Errmsg string            `json:"error,omitempty"`
+}
+
+// namedLevelHandler 查询和修改按名称设置的日志级别
+// curl -X GET http://host:port/names
+// curl -X PUT http://host:port/names -d '{"name":"logging.gorm","level":"debug"}'
+// curl -X DELETE http://host:port/names -d '{"name":"logging.gorm"}'
+func namedLevelHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := jsoniter.NewEncoder(w)
+	fail := func(code int, err error) {
+		w.WriteHeader(code)
+		enc.Encode(namedLevelsPayload{Errmsg: err.Error()})
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost, http.MethodDelete:
+		var req namedLevelsPayload
+		if err := jsoniter.NewDecoder(r.Body).Decode(&req); err != nil {
+			fail(http.StatusBadRequest, fmt.Errorf("request body must be json: %w", err))
+			return
+		}
+		if r.Method == http.MethodDelete {
+			DeleteNamedLevel(req.Name)
+		} else if err := SetNamedLevel(req.Name, req.Level); err != nil {
+			fail(http.StatusBadRequest, err)
+			return
+		}
+	default:
+		fail(http.StatusMethodNotAllowed, fmt.Errorf("only GET, PUT, POST and DELETE are supported"))
+		return
+	}
+	enc.Encode(namedLevelsPayload{Names: NamedLevels()})
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNamedLevelCore(t *testing.T) {
+	obsCore, logs := observer.New(zap.DebugLevel)
+	root := zap.New(newNamedLevelCore(obsCore, zap.NewAtomicLevelAt(zap.InfoLevel))).Named("logging")
+	t.Cleanup(func() {
+		DeleteNamedLevel("logging.gorm")
+		DeleteNamedLevel("logging.gin.*")
+		DeleteNamedLevel("logging.gin.access")
+	})
+
+	if err := SetNamedLevel("logging.gorm", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetNamedLevel("logging.gin.*", "DEBUG"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetNamedLevel("logging.gin.access", "error"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetNamedLevel("logging.x", "verbose"); err == nil {
+		t.Fatal("invalid level should return error")
+	}
+
+	root.Named("gorm").Debug("gorm debug")
+	root.Named("gorm").Named("sub").Debug("gorm sub debug")
+	root.Named("gin").Debug("gin debug")
+	root.Named("gin").Named("handler").Debug("gin handler debug")
+	root.Named("gin").Named("access").Warn("gin access warn")
+	root.Named("ctx_logger").Debug("ctx_logger debug")
+	root.Named("ctx_logger").Info("ctx_logger info")
+
+	var msgs []string
+	for _, entry := range logs.All() {
+		msgs = append(msgs, entry.Message)
+	}
+	expected := "gorm debug,gin debug,gin handler debug,ctx_logger info"
+	if strings.Join(msgs, ",") != expected {
+		t.Fatalf("got %v, expected %s", msgs, expected)
+	}
+
+	DeleteNamedLevel("logging.gorm")
+	root.Named("gorm").Debug("gorm debug after delete")
+	if logs.FilterMessage("gorm debug after delete").Len() != 0 {
+		t.Fatal("deleted name should fall back to global level")
+	}
+}
+
+func TestNamedLevelHandler(t *testing.T) {
+	t.Cleanup(func() { DeleteNamedLevel("logging.gorm") })
+	do := func(method, body string) (int, namedLevelsPayload) {
+		w := httptest.NewRecorder()
+		namedLevelHandler(w, httptest.NewRequest(method, "/names", strings.NewReader(body)))
+		var rsp namedLevelsPayload
+		if err := jsoniter.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, rsp
+	}
+
+	if code, rsp := do(http.MethodPut, `{"name":"logging.gorm","level":"debug"}`); code != http.StatusOK || rsp.Names["logging.gorm"] != "debug" {
+		t.Fatal("put named level failed", code, rsp)
+	}
+	if code, rsp := do(http.MethodGet, ""); code != http.StatusOK || rsp.Names["logging.gorm"] != "debug" {
+		t.Fatal("get named levels failed", code, rsp)
+	}
+	if code, rsp := do(http.MethodPut, `{"name":"logging.gorm","level":"verbose"}`); code != http.StatusBadRequest || rsp.Errmsg == "" {
+		t.Fatal("invalid level should return 400", code, rsp)
+	}
+	if code, rsp := do(http.MethodDelete, `{"name":"logging.gorm"}`); code != http.StatusOK || len(rsp.Names) != 0 {
+		t.Fatal("delete named level failed", code, rsp)
+	}
+}
@@ -0,0 +1,133 @@
+// 日志输出
+// 每个日志输出可以使用不同的日志格式和 EncoderConfig ，例如开发环境在终端输出彩色的 console 日志，同时在文件中写入 json 日志
+
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// ColorAuto 日志输出为终端时使用彩色日志级别
+	ColorAuto = "auto"
+	// ColorAlways 总是使用彩色日志级别
+	ColorAlways = "always"
+	// ColorNever 不使用彩色日志级别
+	ColorNever = "never"
+)
+
+// Output 日志输出配置，设置了 Options.Outputs 时代替 Options.OutputPaths 和 Options.Format
+type Output struct {
+	Path          string                 // 日志输出位置，与 OutputPaths 中的格式相同
+	Format        string                 // 日志格式 json, console ，为空时使用 Options.Format
+	EncoderConfig *zapcore.EncoderConfig // 为 nil 时使用 Options.EncoderConfig
+	Color         string                 // console 格式的日志级别颜色 auto, always, never ，为空时为 auto
+}
+
+// isTerminal 判断日志输出是否为终端，只有 stdout 和 stderr 可能是终端
+var isTerminal = func(path string) bool {
+	var f *os.File
+	switch path {
+	case "stdout":
+		f = os.Stdout
+	case "stderr":
+		f = os.Stderr
+	default:
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// validateOutput 检查日志输出配置
+func validateOutput(output Output) error {
+	if output.Path == "" {
+		return errors.New("output path is required")
+	}
+	if f := strings.ToLower(output.Format); f != "" && f != "json" && f != "console" {
+		return fmt.Errorf("invalid output format: %s", output.Format)
+	}
+	switch strings.ToLower(output.Color) {
+	case "", ColorAuto, ColorAlways, ColorNever:
+	default:
+		return fmt.Errorf("invalid output color: %s", output.Color)
+	}
+	return nil
+}
+
+// useColor 日志输出是否使用彩色日志级别
+func (o Output) useColor(format string) bool {
+	if strings.ToLower(format) != "console" {
+		return false
+	}
+	switch strings.ToLower(o.Color) {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	return isTerminal(o.Path)
+}
+
+// colorLevelEncoder 返回 encoder 对应的彩色日志级别 encoder ，小写的使用小写彩色，其他使用大写彩色
+func colorLevelEncoder(encoder zapcore.LevelEncoder) zapcore.LevelEncoder {
+	if encoder != nil && reflect.ValueOf(encoder).Pointer() == reflect.ValueOf(zapcore.LowercaseLevelEncoder).Pointer() {
+		return zapcore.LowercaseColorLevelEncoder
+	}
+	return zapcore.CapitalColorLevelEncoder
+}
+
+// outputsPaths 返回 outputs 的日志输出位置
+func outputsPaths(outputs []Output) []string {
+	paths := make([]string, 0, len(outputs))
+	for _, output := range outputs {
+		paths = append(paths, output.Path)
+	}
+	return paths
+}
+
+// newOutputsCore 为每个日志输出使用各自的日志格式和 EncoderConfig 创建 core ，组成 tee ，返回关闭日志输出的函数
+func newOutputsCore(outputs []Output, format string, encoderConfig zapcore.EncoderConfig, async *AsyncOption) (zapcore.Core, func(), error) {
+	var closers []func()
+	closer := func() {
+		for _, closeOut := range closers {
+			closeOut()
+		}
+	}
+	cores := make([]zapcore.Core, 0, len(outputs))
+	for _, output := range outputs {
+		if err := validateOutput(output); err != nil {
+			closer()
+			return nil, nil, err
+		}
+		sink, closeOut, err := openOutput([]string{output.Path}, async)
+		if err != nil {
+			closer()
+			return nil, nil, err
+		}
+		closers = append(closers, closeOut)
+		outputFormat := output.Format
+		if outputFormat == "" {
+			outputFormat = format
+		}
+		outputEncoderConfig := encoderConfig
+		if output.EncoderConfig != nil {
+			outputEncoderConfig = *output.EncoderConfig
+		}
+		if output.useColor(outputFormat) {
+			outputEncoderConfig.EncodeLevel = colorLevelEncoder(outputEncoderConfig.EncodeLevel)
+		}
+		cores = append(cores, zapcore.NewCore(newEncoder(outputFormat, outputEncoderConfig), sink, zap.DebugLevel))
+	}
+	return zapcore.NewTee(cores...), closer, nil
+}
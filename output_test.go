@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestOutputs(t *testing.T) {
+	dir := t.TempDir()
+	consolePath := filepath.Join(dir, "console.log")
+	colorPath := filepath.Join(dir, "color.log")
+	jsonPath := filepath.Join(dir, "json.log")
+	// 模拟 console.log 为终端
+	defer func(f func(string) bool) { isTerminal = f }(isTerminal)
+	isTerminal = func(path string) bool { return path == consolePath }
+
+	lowercase := EncoderConfig
+	lowercase.EncodeLevel = zapcore.LowercaseLevelEncoder
+	logger, closer, err := buildLogger(Options{
+		Format: "json",
+		Outputs: []Output{
+			{Path: consolePath, Format: "console"},
+			{Path: colorPath, Format: "console", EncoderConfig: &lowercase, Color: ColorAlways},
+			{Path: jsonPath, Color: ColorAlways},
+		},
+	}, zap.NewAtomicLevelAt(zap.DebugLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("outputs info", zap.String("k", "v"))
+	closer()
+
+	consoleContent := readLogFile(t, consolePath)
+	if strings.HasPrefix(consoleContent, "{") || !strings.Contains(consoleContent, "\x1b[34mINFO\x1b[0m") {
+		t.Fatal("terminal console output should use capital color level:", consoleContent)
+	}
+	colorContent := readLogFile(t, colorPath)
+	if !strings.Contains(colorContent, "\x1b[34minfo\x1b[0m") {
+		t.Fatal("console output should use lowercase color level:", colorContent)
+	}
+	jsonContent := readLogFile(t, jsonPath)
+	if !strings.HasPrefix(jsonContent, "{") || strings.Contains(jsonContent, "\x1b[") || !strings.Contains(jsonContent, `"k":"v"`) {
+		t.Fatal("json output should not use color level:", jsonContent)
+	}
+
+	for _, output := range []Output{{}, {Path: "stdout", Format: "xml"}, {Path: "stdout", Color: "x"}} {
+		if _, _, err := buildLogger(Options{Outputs: []Output{output}}, zap.NewAtomicLevel()); err == nil {
+			t.Fatal("invalid output should return error", output)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// 基于 key-value 载体的 trace 信息传递
+// 消息队列的消息 header 、定时任务的参数等没有 http 请求的场景，通过 Carrier 注入和提取 trace id 、 traceparent 和 tracestate
+
+package logging
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+// Carrier 保存 trace 信息的 key-value 载体，与 OpenTelemetry 的 propagation.TextMapCarrier 兼容
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// MapCarrier 使用 map[string]string 作为 Carrier
+type MapCarrier map[string]string
+
+// Get Carrier interface
+func (c MapCarrier) Get(key string) string {
+	return c[key]
+}
+
+// Set Carrier interface
+func (c MapCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// HeaderCarrier 使用 http.Header 作为 Carrier
+type HeaderCarrier http.Header
+
+// Get Carrier interface
+func (c HeaderCarrier) Get(key string) string {
+	return http.Header(c).Get(key)
+}
+
+// Set Carrier interface
+func (c HeaderCarrier) Set(key, value string) {
+	http.Header(c).Set(key, value)
+}
+
+// MetadataCarrier 使用 grpc metadata.MD 作为 Carrier
+type MetadataCarrier metadata.MD
+
+// Get Carrier interface
+func (c MetadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set Carrier interface
+func (c MetadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// InjectTraceContext 将 context 中的 trace id 设置到 carrier 中，满足 W3C 格式时同时设置 traceparent 和 tracestate
+// context 中没有 trace id 时会生成新的 trace id ，需要在日志中使用同一个 trace id 时先通过 NewCtxLogger 保存到 context 中
+func InjectTraceContext(c context.Context, carrier Carrier) {
+	if c == nil {
+		c = context.Background()
+	}
+	carrier.Set(string(TraceIDKeyname), CtxTraceID(c))
+	if traceparent := CtxTraceparent(c); traceparent != "" {
+		carrier.Set(TraceparentHeader, traceparent)
+		if tracestate := CtxTracestate(c); tracestate != "" {
+			carrier.Set(TracestateHeader, tracestate)
+		}
+	}
+}
+
+// GetTraceIDFromCarrier 从 carrier 中获取 key 为 TraceIDKeyname 的值作为 trace id
+// 不存在时尝试从 W3C traceparent 中获取
+func GetTraceIDFromCarrier(carrier Carrier) string {
+	if traceID := carrier.Get(string(TraceIDKeyname)); traceID != "" {
+		return traceID
+	}
+	traceID, _, _, _ := ParseTraceparent(carrier.Get(TraceparentHeader))
+	return traceID
+}
+
+// ExtractTraceContext 将 carrier 中的 trace id 和 tracestate 设置到 context 中
+// trace id 符合 W3C 格式时生成本服务的 span id ， carrier 中 traceparent 的 span id 作为 parent span id
+// 返回的 context 可以通过 CtxTraceID 获取 trace id ，使用 NewCtxLogger 创建带有该 trace id 的 ctx logger
+// 与 gin 中间件一样， carrier 中的 trace id 不满足全局的 trace id 校验函数时截断后记录在 invalid_trace_id 字段中，
+// 并尝试使用 traceparent 中的 trace id ，都没有时返回的 context 中没有 trace id ， NewCtxLogger 会生成新的 trace id
+func ExtractTraceContext(c context.Context, carrier Carrier) context.Context {
+	if c == nil {
+		c = context.Background()
+	}
+	validator := GetTraceIDValidator()
+	w3cTraceID, incomingSpanID, _, _ := ParseTraceparent(carrier.Get(TraceparentHeader))
+	traceID := carrier.Get(string(TraceIDKeyname))
+	if traceID != "" && !validTraceID(traceID, validator) {
+		c = context.WithValue(c, InvalidTraceIDKeyname, truncateTraceID(traceID))
+		traceID = ""
+	}
+	if traceID == "" {
+		if traceID = w3cTraceID; !validTraceID(traceID, validator) {
+			return c
+		}
+	}
+	spanID, parentSpanID := newServerSpan(c, traceID, w3cTraceID, incomingSpanID)
+	c = context.WithValue(c, TraceIDKeyname, traceID)
+	if spanID != "" {
+		c = context.WithValue(c, SpanIDKeyname, spanID)
+	}
+	// traceparent 属于同一个 trace 时其中的 span id 作为 parent span id ，并保留 tracestate
+	if parentSpanID != "" {
+		c = context.WithValue(c, ParentSpanIDKeyname, parentSpanID)
+		if tracestate := carrier.Get(TracestateHeader); tracestate != "" {
+			c = context.WithValue(c, TracestateKeyname, tracestate)
+		}
+	}
+	return c
+}
+
+// WrapConsumerHandler 包装消息队列的消息处理函数，每条消息使用 carrier 返回的 trace 信息创建自己的 ctx logger
+// 消息中没有 trace id 时生成新的 trace id ，处理函数返回 error 时打印 error 日志
+// handler := logging.WrapConsumerHandler("kafka_consumer", func(msg *kafka.Message) logging.Carrier {...}, handle)
+func WrapConsumerHandler[M any](name string, carrier func(msg M) Carrier, handler func(c context.Context, msg M) error) func(c context.Context, msg M) error {
+	return func(c context.Context, msg M) error {
+		start := time.Now()
+		if c == nil {
+			c = context.Background()
+		}
+		c = ExtractTraceContext(c, carrier(msg))
+		c, ctxLogger := NewCtxLogger(c, CloneLogger(name), "")
+		err := handler(c, msg)
+		if err != nil {
+			ctxLogger.Error("consumer handle message error", zap.Error(err), zap.Float64("latency_seconds", time.Since(start).Seconds()))
+		}
+		return err
+	}
+}
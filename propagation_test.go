@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestInjectExtractTraceContext(t *testing.T) {
+	traceID := "4bf92f3577b34e4ea0f7d8b1d9a5c3e1"
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("test"), traceID)
+	ctx = context.WithValue(context.WithValue(ctx, SpanIDKeyname, NewSpanID()), TracestateKeyname, "k=v")
+	for _, carrier := range []Carrier{MapCarrier{}, HeaderCarrier(http.Header{}), MetadataCarrier(metadata.MD{})} {
+		InjectTraceContext(ctx, carrier)
+		if GetTraceIDFromCarrier(carrier) != traceID || carrier.Get(TraceparentHeader) == "" || carrier.Get(TracestateHeader) != "k=v" {
+			t.Fatalf("invalid injected carrier: %#v", carrier)
+		}
+		extracted := ExtractTraceContext(nil, carrier)
+		if CtxTraceID(extracted) != traceID || CtxTracestate(extracted) != "k=v" {
+			t.Fatal("invalid extracted context", CtxTraceID(extracted), CtxTracestate(extracted))
+		}
+		// 生成新的 span id ，注入的 span id 作为 parent span id
+		if spanID := CtxSpanID(extracted); spanID == "" || spanID == CtxSpanID(ctx) || ctxStoredParentSpanID(extracted) != CtxSpanID(ctx) {
+			t.Fatal("invalid extracted span", spanID, ctxStoredParentSpanID(extracted))
+		}
+		_, logger := NewCtxLogger(extracted, CloneLogger("test"), "")
+		logger.Info("extracted")
+	}
+
+	// 只有 traceparent 时使用其中的 trace id
+	carrier := MapCarrier{TraceparentHeader: FormatTraceparent(traceID, NewSpanID(), 1)}
+	if CtxTraceID(ExtractTraceContext(context.Background(), carrier)) != traceID {
+		t.Fatal("should extract trace id from traceparent")
+	}
+	if c := context.Background(); ExtractTraceContext(c, MapCarrier{}) != c {
+		t.Fatal("empty carrier should return the original context")
+	}
+
+	// trace id 不合法时记录在 invalid_trace_id 中，并使用 traceparent 中的 trace id
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+	carrier[string(TraceIDKeyname)] = "invalid\ntrace id"
+	extracted := ExtractTraceContext(context.Background(), carrier)
+	if CtxTraceID(extracted) != traceID {
+		t.Fatal("invalid trace id should fall back to traceparent", CtxTraceID(extracted))
+	}
+	delete(carrier, TraceparentHeader)
+	_, logger := NewCtxLogger(ExtractTraceContext(context.Background(), carrier), CloneLogger("test"), "")
+	logger.Info("invalid")
+	fields := logs.AllUntimed()[0].ContextMap()
+	if fields[string(InvalidTraceIDKeyname)] != "invalid\ntrace id" || fields[string(TraceIDKeyname)] == "" || fields[string(TraceIDKeyname)] == "invalid\ntrace id" {
+		t.Fatal("invalid trace id should be recorded and replaced", fields)
+	}
+}
+
+func TestWrapConsumerHandler(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+
+	type message struct {
+		headers map[string]string
+		body    string
+	}
+	var traceIDs []string
+	handler := WrapConsumerHandler("consumer", func(msg message) Carrier {
+		return MapCarrier(msg.headers)
+	}, func(c context.Context, msg message) error {
+		traceIDs = append(traceIDs, CtxTraceID(c))
+		Info(c, "handle "+msg.body)
+		if msg.body == "bad" {
+			return errors.New("bad message")
+		}
+		return nil
+	})
+	messages := []message{
+		{headers: map[string]string{string(TraceIDKeyname): "msg-trace-id"}, body: "good"},
+		{headers: map[string]string{}, body: "bad"},
+	}
+	if err := handler(context.Background(), messages[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler(context.Background(), messages[1]); err == nil {
+		t.Fatal("handler error should be returned")
+	}
+
+	if traceIDs[0] != "msg-trace-id" || traceIDs[1] == "" || traceIDs[1] == traceIDs[0] {
+		t.Fatal("each message should have its own trace id", traceIDs)
+	}
+	entries := logs.AllUntimed()
+	if len(entries) != 3 || entries[0].ContextMap()[string(TraceIDKeyname)] != "msg-trace-id" {
+		t.Fatal("invalid consumer logs", entries)
+	}
+	if entries[2].Level != zap.ErrorLevel || entries[2].LoggerName != "consumer" || entries[2].ContextMap()[string(TraceIDKeyname)] != traceIDs[1] {
+		t.Fatal("handler error should be logged with the message trace id", entries[2])
+	}
+}
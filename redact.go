@@ -0,0 +1,470 @@
+// 敏感信息脱敏
+// 支持按字段名、 JSON 路径匹配字段，按正则匹配字段值，匹配到的内容使用 mask 、 hash 或 partial 方式脱敏
+
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RedactStrategy 脱敏方式
+type RedactStrategy string
+
+const (
+	// RedactMask 替换为固定的 RedactMaskString
+	RedactMask RedactStrategy = "mask"
+	// RedactHash 替换为 sha256 摘要的前 16 位，相同的值脱敏后相同，便于关联排查
+	RedactHash RedactStrategy = "hash"
+	// RedactPartial 保留首尾部分字符，中间替换为 *
+	RedactPartial RedactStrategy = "partial"
+
+	// RedactMaskString mask 方式脱敏后的值
+	RedactMaskString = "******"
+
+	// RedactPatternCardNumber 匹配 13-19 位银行卡号，可用于 RedactPattern
+	RedactPatternCardNumber = `\b\d{4}[ -]?\d{4}[ -]?\d{4}[ -]?\d{1,7}\b`
+	// RedactPatternPhoneCN 匹配中国大陆手机号，可用于 RedactPattern
+	RedactPatternPhoneCN = `\b1[3-9]\d{9}\b`
+)
+
+var (
+	// DefaultRedactFields 默认需要脱敏的字段名，大小写不敏感
+	DefaultRedactFields = []string{
+		"authorization",
+		"proxy-authorization",
+		"cookie",
+		"set-cookie",
+		"password",
+		"passwd",
+		"secret",
+		"token",
+		"access_token",
+		"refresh_token",
+		"api_key",
+		"apikey",
+		"x-api-key",
+	}
+
+	// DefaultRedactor 使用默认字段名的脱敏器， GinLoggerConfig 未设置 Redactor 时使用
+	DefaultRedactor, _ = NewRedactor(RedactorConfig{Fields: DefaultRedactFields})
+
+	// 将其他类型转换为 JSON 结构时使用，数字解析为 json.Number
+	redactJSON = jsoniter.Config{UseNumber: true}.Froze()
+)
+
+// RedactPattern 按正则匹配字段值中需要脱敏的内容
+type RedactPattern struct {
+	// 正则表达式
+	Pattern string `json:"pattern" yaml:"pattern" toml:"pattern"`
+	// 匹配内容的脱敏方式，默认使用 RedactorConfig.Strategy
+	Strategy RedactStrategy `json:"strategy" yaml:"strategy" toml:"strategy"`
+}
+
+// RedactorConfig 脱敏器配置
+type RedactorConfig struct {
+	// 需要脱敏的字段名，匹配任意层级中的 key ，大小写不敏感
+	Fields []string `json:"fields" yaml:"fields" toml:"fields"`
+	// 需要脱敏的 JSON 路径，以 . 分隔，从 zap field 的 key 开始，如 request_body.user.password
+	// 路径中可以使用 * 匹配任意 key ，数组不占用路径层级
+	JSONPaths []string `json:"json_paths" yaml:"json_paths" toml:"json_paths"`
+	// 按正则匹配字符串值中需要脱敏的内容
+	Patterns []RedactPattern `json:"patterns" yaml:"patterns" toml:"patterns"`
+	// 字段名和 JSON 路径匹配时的脱敏方式，默认为 RedactMask
+	Strategy RedactStrategy `json:"strategy" yaml:"strategy" toml:"strategy"`
+	// RedactPartial 方式首尾最多保留的字符数，默认为 4 ，最多保留总长度的 1/4
+	PartialKeep int `json:"partial_keep" yaml:"partial_keep" toml:"partial_keep"`
+}
+
+// Redactor 脱敏器
+type Redactor struct {
+	fields      map[string]struct{}
+	jsonPaths   [][]string
+	patterns    []*regexp.Regexp
+	strategies  []RedactStrategy
+	strategy    RedactStrategy
+	partialKeep int
+}
+
+// NewRedactor 创建脱敏器，正则或脱敏方式不合法时返回 error
+func NewRedactor(cfg RedactorConfig) (*Redactor, error) {
+	r := &Redactor{
+		fields:      make(map[string]struct{}, len(cfg.Fields)),
+		strategy:    cfg.Strategy,
+		partialKeep: cfg.PartialKeep,
+	}
+	if r.strategy == "" {
+		r.strategy = RedactMask
+	}
+	if !validRedactStrategy(r.strategy) {
+		return nil, fmt.Errorf("invalid redact strategy: %s", r.strategy)
+	}
+	if r.partialKeep <= 0 {
+		r.partialKeep = 4
+	}
+	for _, field := range cfg.Fields {
+		r.fields[strings.ToLower(field)] = struct{}{}
+	}
+	for _, p := range cfg.JSONPaths {
+		r.jsonPaths = append(r.jsonPaths, strings.Split(strings.ToLower(p), "."))
+	}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %s: %w", p.Pattern, err)
+		}
+		strategy := p.Strategy
+		if strategy == "" {
+			strategy = r.strategy
+		}
+		if !validRedactStrategy(strategy) {
+			return nil, fmt.Errorf("invalid redact strategy: %s", strategy)
+		}
+		r.patterns = append(r.patterns, re)
+		r.strategies = append(r.strategies, strategy)
+	}
+	return r, nil
+}
+
+func validRedactStrategy(strategy RedactStrategy) bool {
+	switch strategy {
+	case RedactMask, RedactHash, RedactPartial:
+		return true
+	}
+	return false
+}
+
+// Redact 对 key 对应的值进行脱敏，返回脱敏后的副本，不会修改原始值
+// 支持 map 、 slice 、 http.Header 、 url.Values 和字符串，其他类型会先转换为 JSON 结构再处理
+func (r *Redactor) Redact(key string, value interface{}) interface{} {
+	if r == nil {
+		return value
+	}
+	redacted, _ := r.redact([]string{key}, value)
+	return redacted
+}
+
+// RedactString 按正则对字符串中匹配的内容脱敏
+func (r *Redactor) RedactString(s string) string {
+	if r == nil {
+		return s
+	}
+	for i, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, func(m string) string {
+			return r.apply(r.strategies[i], m)
+		})
+	}
+	return s
+}
+
+// RedactFields 对 zap field 进行脱敏，返回新的 field 列表，不会修改传入的 fields
+func (r *Redactor) RedactFields(fields []zap.Field) []zap.Field {
+	if r == nil || len(fields) == 0 {
+		return fields
+	}
+	redacted := make([]zap.Field, len(fields))
+	for i, f := range fields {
+		redacted[i] = r.redactField(f)
+	}
+	return redacted
+}
+
+func (r *Redactor) redactField(f zap.Field) zap.Field {
+	switch f.Type {
+	case zapcore.NamespaceType, zapcore.SkipType:
+		return f
+	}
+	path := []string{f.Key}
+	if r.matchPath(path) {
+		return zap.String(f.Key, r.apply(r.strategy, fmt.Sprint(fieldValue(f))))
+	}
+	switch f.Type {
+	case zapcore.StringType:
+		if redacted := r.RedactString(f.String); redacted != f.String {
+			return zap.String(f.Key, redacted)
+		}
+	case zapcore.ReflectType:
+		if redacted, changed := r.redact(path, f.Interface); changed {
+			return zap.Any(f.Key, redacted)
+		}
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.InlineMarshalerType:
+		// 只有内容被脱敏时才使用脱敏后的结构替换原始的 marshaler
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		if f.Type == zapcore.InlineMarshalerType {
+			if redacted, changed := r.redact(nil, enc.Fields); changed {
+				return zap.Inline(redactedObject(redacted.(map[string]interface{})))
+			}
+		} else if redacted, changed := r.redact(path, enc.Fields[f.Key]); changed {
+			return zap.Any(f.Key, redacted)
+		}
+	}
+	return f
+}
+
+// fieldValue 获取 zap field 的值
+func fieldValue(f zap.Field) interface{} {
+	if f.Type == zapcore.StringType {
+		return f.String
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	return enc.Fields[f.Key]
+}
+
+// redactedObject 用于输出 inline 字段脱敏后的结果
+type redactedObject map[string]interface{}
+
+// MarshalLogObject 实现 zapcore.ObjectMarshaler 接口
+func (o redactedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for k, v := range o {
+		zap.Any(k, v).AddTo(enc)
+	}
+	return nil
+}
+
+// redact 返回脱敏后的值，以及是否有内容被脱敏，没有时返回原始值
+func (r *Redactor) redact(path []string, value interface{}) (interface{}, bool) {
+	if len(path) > 0 && r.matchPath(path) {
+		switch v := value.(type) {
+		case []string:
+			masked := make([]string, len(v))
+			for i, s := range v {
+				masked[i] = r.apply(r.strategy, s)
+			}
+			return masked, true
+		case nil:
+			return nil, false
+		}
+		return r.apply(r.strategy, fmt.Sprint(value)), true
+	}
+	switch v := value.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return v, false
+	case string:
+		redacted := r.RedactString(v)
+		return redacted, redacted != v
+	case []byte:
+		if redacted := r.RedactString(string(v)); redacted != string(v) {
+			return redacted, true
+		}
+		return v, false
+	case []string:
+		redacted := make([]string, len(v))
+		changed := false
+		for i, s := range v {
+			redacted[i] = r.RedactString(s)
+			changed = changed || redacted[i] != s
+		}
+		if !changed {
+			return v, false
+		}
+		return redacted, true
+	case http.Header:
+		if redacted, changed := r.redactStringsMap(path, v); changed {
+			return http.Header(redacted), true
+		}
+		return v, false
+	case url.Values:
+		if redacted, changed := r.redactStringsMap(path, v); changed {
+			return url.Values(redacted), true
+		}
+		return v, false
+	case map[string][]string:
+		if redacted, changed := r.redactStringsMap(path, v); changed {
+			return redacted, true
+		}
+		return v, false
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		changed := false
+		for k, item := range v {
+			var itemChanged bool
+			redacted[k], itemChanged = r.redact(appendPath(path, k), item)
+			changed = changed || itemChanged
+		}
+		if !changed {
+			return v, false
+		}
+		return redacted, true
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		changed := false
+		for i, item := range v {
+			var itemChanged bool
+			redacted[i], itemChanged = r.redact(path, item)
+			changed = changed || itemChanged
+		}
+		if !changed {
+			return v, false
+		}
+		return redacted, true
+	}
+	// 其他类型转换为 JSON 结构后处理，数字使用 json.Number 避免大整数丢失精度，没有内容被脱敏时保留原始值
+	b, err := jsoniter.Marshal(value)
+	if err != nil {
+		return value, false
+	}
+	var generic interface{}
+	if err := redactJSON.Unmarshal(b, &generic); err != nil {
+		return value, false
+	}
+	if redacted, changed := r.redact(path, generic); changed {
+		return redacted, true
+	}
+	return value, false
+}
+
+func (r *Redactor) redactStringsMap(path []string, m map[string][]string) (map[string][]string, bool) {
+	if m == nil {
+		return nil, false
+	}
+	redacted := make(map[string][]string, len(m))
+	changed := false
+	for k, values := range m {
+		v, valuesChanged := r.redact(appendPath(path, k), values)
+		redacted[k] = v.([]string)
+		changed = changed || valuesChanged
+	}
+	return redacted, changed
+}
+
+// appendPath 返回新的路径，避免共享底层数组
+func appendPath(path []string, key string) []string {
+	newPath := make([]string, len(path)+1)
+	copy(newPath, path)
+	newPath[len(path)] = key
+	return newPath
+}
+
+// matchPath 判断路径最后的 key 是否为需要脱敏的字段名，或者路径是否匹配 JSON 路径
+func (r *Redactor) matchPath(path []string) bool {
+	if _, exists := r.fields[strings.ToLower(path[len(path)-1])]; exists {
+		return true
+	}
+	for _, jsonPath := range r.jsonPaths {
+		if len(jsonPath) != len(path) {
+			continue
+		}
+		matched := true
+		for i, seg := range jsonPath {
+			if seg != "*" && seg != strings.ToLower(path[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// apply 使用指定的脱敏方式处理字符串
+func (r *Redactor) apply(strategy RedactStrategy, s string) string {
+	switch strategy {
+	case RedactHash:
+		sum := sha256.Sum256([]byte(s))
+		return "sha256:" + hex.EncodeToString(sum[:])[:16]
+	case RedactPartial:
+		runes := []rune(s)
+		keep := len(runes) / 4
+		if keep > r.partialKeep {
+			keep = r.partialKeep
+		}
+		if keep == 0 {
+			return RedactMaskString
+		}
+		return string(runes[:keep]) + strings.Repeat("*", len(runes)-2*keep) + string(runes[len(runes)-keep:])
+	default:
+		return RedactMaskString
+	}
+}
+
+// redactCore 在写入日志前对字段进行脱敏
+type redactCore struct {
+	zapcore.Core
+	redactor *Redactor
+}
+
+// NewRedactCore 返回对日志字段和 msg 进行脱敏的 core
+// core 可以是 NewTee 创建的 core ，只写入 tee 中 Check 时接受了日志的 core
+func NewRedactCore(core zapcore.Core, redactor *Redactor) zapcore.Core {
+	if redactor == nil {
+		return core
+	}
+	return &redactCore{Core: core, redactor: redactor}
+}
+
+// With zap core interface
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{
+		Core:     c.Core.With(c.redactor.RedactFields(fields)),
+		redactor: c.redactor,
+	}
+}
+
+// Check zap core interface
+// 使用被包装 core 的 Check 判断是否写入，保留 sampler 、 routeCore 等 core 的判断逻辑
+// tee 中每个 core 的判断结果保存在单独的 CheckedEntry 中，写入时只写入接受了日志的 core
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if checked := c.Core.Check(ent, nil); checked != nil {
+		return ce.AddCore(ent, &redactCheckedCore{Core: c.Core, redactor: c.redactor, checked: checked})
+	}
+	return ce
+}
+
+// Write zap core interface
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = c.redactor.RedactString(ent.Message)
+	return c.Core.Write(ent, c.redactor.RedactFields(fields))
+}
+
+// redactCheckedCore 脱敏后写入被包装 core Check 时返回的 CheckedEntry
+type redactCheckedCore struct {
+	zapcore.Core
+	redactor *Redactor
+	checked  *zapcore.CheckedEntry
+	errs     redactWriteErrors
+}
+
+// Write zap core interface
+func (c *redactCheckedCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	// CheckedEntry 写入后会放回对象池，只能写入一次
+	checked := c.checked
+	if checked == nil {
+		return nil
+	}
+	c.checked = nil
+	checked.Entry.Message = c.redactor.RedactString(ent.Message)
+	// 写入的错误由外层的 CheckedEntry 输出到 ErrorOutput
+	checked.ErrorOutput = &c.errs
+	checked.Write(c.redactor.RedactFields(fields)...)
+	if len(c.errs) > 0 {
+		return errors.New(strings.TrimSpace(string(c.errs)))
+	}
+	return nil
+}
+
+// redactWriteErrors 收集 CheckedEntry 写入时输出的错误信息
+type redactWriteErrors []byte
+
+func (e *redactWriteErrors) Write(p []byte) (int, error) {
+	*e = append(*e, p...)
+	return len(p), nil
+}
+
+func (e *redactWriteErrors) Sync() error {
+	return nil
+}
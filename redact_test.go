@@ -0,0 +1,172 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedactor(t *testing.T) {
+	r, err := NewRedactor(RedactorConfig{
+		Fields:    []string{"Password"},
+		JSONPaths: []string{"body.user.*.card"},
+		Patterns: []RedactPattern{
+			{Pattern: RedactPatternPhoneCN, Strategy: RedactPartial},
+			{Pattern: `secret-\w+`, Strategy: RedactHash},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := map[string]interface{}{
+		"password": "123456",
+		"user": map[string]interface{}{
+			"alice": map[string]interface{}{"card": "6222020000000000", "name": "alice"},
+		},
+		"items": []interface{}{map[string]interface{}{"PASSWORD": "abc"}},
+		"phone": "my phone is 13800138000",
+		"note":  "secret-abc",
+	}
+	redacted := r.Redact("body", body).(map[string]interface{})
+	if redacted["password"] != RedactMaskString {
+		t.Error("password should be masked", redacted)
+	}
+	alice := redacted["user"].(map[string]interface{})["alice"].(map[string]interface{})
+	if alice["card"] != RedactMaskString || alice["name"] != "alice" {
+		t.Error("invalid json path redact", alice)
+	}
+	if item := redacted["items"].([]interface{})[0].(map[string]interface{}); item["PASSWORD"] != RedactMaskString {
+		t.Error("field in array should be masked", item)
+	}
+	if redacted["phone"] != "my phone is 13*******00" {
+		t.Error("invalid partial redact", redacted["phone"])
+	}
+	if note := redacted["note"].(string); !strings.HasPrefix(note, "sha256:") || note == r.Redact("note", "secret-abd") {
+		t.Error("invalid hash redact", note)
+	}
+	if body["password"] != "123456" {
+		t.Error("origin value should not be modified")
+	}
+
+	header := http.Header{"Authorization": []string{"Bearer xxx"}, "Accept": []string{"*/*"}}
+	rheader := DefaultRedactor.Redact("request_header", header).(http.Header)
+	if rheader.Get("Authorization") != RedactMaskString || rheader.Get("Accept") != "*/*" {
+		t.Error("invalid header redact", rheader)
+	}
+
+	if _, err := NewRedactor(RedactorConfig{Patterns: []RedactPattern{{Pattern: "("}}}); err == nil {
+		t.Error("invalid pattern should return error")
+	}
+	if _, err := NewRedactor(RedactorConfig{Strategy: "unknown"}); err == nil {
+		t.Error("invalid strategy should return error")
+	}
+}
+
+func TestRedactCore(t *testing.T) {
+	r, _ := NewRedactor(RedactorConfig{
+		Fields:   DefaultRedactFields,
+		Patterns: []RedactPattern{{Pattern: RedactPatternCardNumber}},
+	})
+	obsCore, logs := observer.New(zap.DebugLevel)
+	core := zapcore.NewSamplerWithOptions(NewRedactCore(obsCore, r), time.Second, 1, 0)
+	l := zap.New(core).With(zap.String("token", "t1"))
+	l.Info("card 6222 0200 0000 0000", zap.Int("password", 123), zap.Any("extra", map[string]string{"secret": "s"}))
+	l.Info("card 6222 0200 0000 0000", zap.Int("password", 123))
+
+	entries := logs.AllUntimed()
+	if len(entries) != 1 {
+		t.Fatal("sampler should still work", len(entries))
+	}
+	m := entries[0].ContextMap()
+	if m["token"] != RedactMaskString || m["password"] != RedactMaskString {
+		t.Error("fields should be masked", m)
+	}
+	if extra := m["extra"].(map[string]interface{}); extra["secret"] != RedactMaskString {
+		t.Error("reflect field should be masked", extra)
+	}
+	if entries[0].Message != "card "+RedactMaskString {
+		t.Error("message should be masked", entries[0].Message)
+	}
+}
+
+func TestGinLoggerRedact(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	app.Use(GinLoggerWithConfig(GinLoggerConfig{EnableRequestHeader: true, EnableRequestBody: true}))
+	app.POST("/redact", func(c *gin.Context) {
+		c.String(500, "error")
+	})
+	req := httptest.NewRequest(http.MethodPost, "/redact?token=abc&k=v", strings.NewReader(`{"password":"123","name":"n"}`))
+	req.Header.Set("Authorization", "Bearer xxx")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.FilterLevelExact(zap.ErrorLevel).AllUntimed()
+	if len(entries) != 1 {
+		t.Fatal("invalid entries count", len(entries))
+	}
+	m := entries[0].ContextMap()
+	if h := m["request_header"].(http.Header); h.Get("Authorization") != RedactMaskString {
+		t.Error("authorization header should be masked", h)
+	}
+	if body := m["request_body"].(map[string]interface{}); body["password"] != RedactMaskString || body["name"] != "n" {
+		t.Error("password should be masked", body)
+	}
+	if query := m["query"].(string); strings.Contains(query, "abc") {
+		t.Error("query token should be masked", query)
+	}
+	if req.Header.Get("Authorization") != "Bearer xxx" {
+		t.Error("origin header should not be modified")
+	}
+}
+
+type redactTestObject struct {
+	ID int64
+}
+
+func (o redactTestObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt64("id", o.ID)
+	return nil
+}
+
+func TestRedactFieldsKeepOriginal(t *testing.T) {
+	r, _ := NewRedactor(RedactorConfig{Fields: DefaultRedactFields})
+	type user struct {
+		ID       int64  `json:"id"`
+		Password string `json:"password"`
+	}
+	type order struct {
+		ID int64 `json:"id"`
+	}
+	const bigID = int64(1<<62 + 1)
+	fields := r.RedactFields([]zap.Field{
+		zap.Any("order", order{ID: bigID}),
+		zap.Any("masked_user", user{ID: bigID, Password: "p"}),
+		zap.Object("obj", redactTestObject{ID: bigID}),
+	})
+	// 没有匹配任何规则的字段保持原样
+	if o, ok := fields[0].Interface.(order); !ok || o.ID != bigID {
+		t.Error("unmatched reflect field should not be rewritten", fields[0])
+	}
+	if fields[2].Type != zapcore.ObjectMarshalerType {
+		t.Error("unmatched object field should not be rewritten", fields[2])
+	}
+	// 脱敏后的大整数不丢失精度
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	buf, err := enc.EncodeEntry(zapcore.Entry{}, fields[1:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); !strings.Contains(s, `"id":4611686018427387905`) || !strings.Contains(s, `"password":"`+RedactMaskString+`"`) {
+		t.Error("invalid masked user", s)
+	}
+}
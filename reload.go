@@ -0,0 +1,228 @@
+// 重新加载全局 logger 配置
+// 全局 logger 使用可以替换的 core ，重新加载时替换 core 并使用 ReplaceLogger 替换全局 logger ，
+// 已经通过 CloneLogger 、 CtxLogger 派生出的 logger 也会写入到新的日志输出中，旧的日志输出会被关闭
+
+package logging
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// 替换 core 后延迟关闭旧的日志输出，等待正在写入的日志完成
+	reloadCloseDelay = time.Second
+	// 配置文件变化后等待一段时间再重新加载，避免编辑器多次写入时重复加载
+	reloadDebounce = 100 * time.Millisecond
+)
+
+var (
+	// rootCore 全局 logger 的可替换 core
+	rootCore = &reloadableRoot{}
+	// 重新加载配置时加锁，保证同一时间只有一个重新加载
+	reloadMutex sync.Mutex
+)
+
+// reloadableRoot 保存当前使用的 core 和关闭它的日志输出的函数
+type reloadableRoot struct {
+	current atomic.Pointer[reloadableGeneration]
+}
+
+// reloadableGeneration 一次加载生成的 core 和 ErrorOutput
+type reloadableGeneration struct {
+	core        zapcore.Core
+	errorOutput zapcore.WriteSyncer
+	closer      func()
+}
+
+// swap 替换为新的 core ，返回旧的 core
+func (r *reloadableRoot) swap(core zapcore.Core, errorOutput zapcore.WriteSyncer, closer func()) *reloadableGeneration {
+	return r.current.Swap(&reloadableGeneration{core: core, errorOutput: errorOutput, closer: closer})
+}
+
+// wrap 替换 core 和 ErrorOutput 并返回使用可替换 core 和 ErrorOutput 的 l
+func (r *reloadableRoot) wrap(l *zap.Logger, errorOutput zapcore.WriteSyncer, closer func()) *zap.Logger {
+	prev := r.swap(l.Core(), errorOutput, closer)
+	if prev != nil && prev.closer != nil {
+		time.AfterFunc(reloadCloseDelay, prev.closer)
+	}
+	return l.WithOptions(zap.WrapCore(func(zapcore.Core) zapcore.Core {
+		return &reloadableCore{root: r}
+	}), zap.ErrorOutput(&reloadableErrorOutput{root: r}))
+}
+
+// reloadableErrorOutput 将 zap 内部错误写入 root 当前的 ErrorOutput ，避免派生的 logger 写入已关闭的旧输出
+type reloadableErrorOutput struct {
+	root *reloadableRoot
+}
+
+// Write io.Writer interface
+func (w *reloadableErrorOutput) Write(p []byte) (int, error) {
+	return w.root.current.Load().errorOutput.Write(p)
+}
+
+// Sync zapcore.WriteSyncer interface
+func (w *reloadableErrorOutput) Sync() error {
+	return w.root.current.Load().errorOutput.Sync()
+}
+
+// reloadableCore 将日志写入 root 当前的 core ， With 添加的字段在 core 替换后重新添加到新的 core 上
+type reloadableCore struct {
+	root   *reloadableRoot
+	fields []zapcore.Field
+	cache  atomic.Pointer[reloadableCoreCache]
+}
+
+// reloadableCoreCache 缓存在 root 当前的 core 上添加字段后的 core
+type reloadableCoreCache struct {
+	generation *reloadableGeneration
+	core       zapcore.Core
+}
+
+func (c *reloadableCore) current() zapcore.Core {
+	generation := c.root.current.Load()
+	if len(c.fields) == 0 {
+		return generation.core
+	}
+	if cache := c.cache.Load(); cache != nil && cache.generation == generation {
+		return cache.core
+	}
+	core := generation.core.With(c.fields)
+	c.cache.Store(&reloadableCoreCache{generation: generation, core: core})
+	return core
+}
+
+// Enabled zap core interface
+func (c *reloadableCore) Enabled(lvl zapcore.Level) bool {
+	return c.current().Enabled(lvl)
+}
+
+// With zap core interface
+func (c *reloadableCore) With(fields []zapcore.Field) zapcore.Core {
+	merged := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	merged = append(merged, c.fields...)
+	merged = append(merged, fields...)
+	return &reloadableCore{root: c.root, fields: merged}
+}
+
+// Check zap core interface
+func (c *reloadableCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.current().Check(ent, ce)
+}
+
+// Write zap core interface
+func (c *reloadableCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.current().Write(ent, fields)
+}
+
+// Sync zap core interface
+func (c *reloadableCore) Sync() error {
+	return c.current().Sync()
+}
+
+// ReloadLogger 使用新的配置重新创建全局 logger 并替换
+// 已经派生出的 logger 会写入到新的日志输出中，但 logger 名称、 caller 和 stacktrace 配置保持不变
+// 配置了合法的 Level 时修改当前 atomic level ，不会重新启动 AtomicLevel server ， AtomicLevelServer 配置会被忽略
+func ReloadLogger(options Options) error {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	if lvl, exists := ZapcoreLevelMap[strings.ToLower(options.Level)]; exists {
+		setLevelTTL(atomicLevel, lvl, 0)
+	}
+	l, errorOutput, closer, err := buildLoggerWithErrorOutput(options, atomicLevel)
+	if err != nil {
+		return err
+	}
+	ReplaceLogger(rootCore.wrap(l, errorOutput, closer))
+	setGlobalOptions(options)
+
+	rwMutex.Lock()
+	sentryClient = options.SentryClient
+	rwMutex.Unlock()
+	return nil
+}
+
+// WatchConfig 监听配置文件变化和 SIGHUP 信号，使用 LoadOptions 加载配置后调用 ReloadLogger 重新加载全局 logger
+// 加载失败时打印错误日志并继续使用当前的 logger ，返回停止监听的函数
+func WatchConfig(path, envPrefix string) (func(), error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// 监听配置文件所在目录，兼容编辑器保存时替换文件和 k8s configmap 更新软链接的情况
+	dir := filepath.Dir(path)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	realPath, _ := filepath.EvalSymlinks(path)
+
+	reload := func(reason string) {
+		options, err := LoadOptions(path, envPrefix)
+		if err != nil {
+			Error(nil, "logging load config error", zap.String("path", path), zap.String("reason", reason), zap.Error(err))
+			return
+		}
+		if err := ReloadLogger(options); err != nil {
+			Error(nil, "logging reload logger error", zap.String("path", path), zap.String("reason", reason), zap.Error(err))
+			return
+		}
+		Info(nil, "logging reloaded config", zap.String("path", path), zap.String("reason", reason))
+	}
+	debounce := time.AfterFunc(time.Duration(1<<63-1), func() { reload("file changed") })
+	debounce.Stop()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+					continue
+				}
+				// 配置文件本身或其软链接的目标发生变化
+				currentPath, _ := filepath.EvalSymlinks(path)
+				if filepath.Clean(event.Name) == filepath.Clean(path) || currentPath != realPath {
+					realPath = currentPath
+					debounce.Reset(reloadDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				Error(nil, "logging watch config error", zap.String("path", path), zap.Error(err))
+			case <-sigs:
+				reload("SIGHUP")
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			debounce.Stop()
+			close(done)
+			watcher.Close()
+		})
+	}
+	return stop, nil
+}
@@ -0,0 +1,129 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func restoreGlobalLogger(t *testing.T) {
+	t.Cleanup(func() {
+		if err := ReloadLogger(Options{Level: "debug", DisableStacktrace: true}); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func readLogFile(t *testing.T, path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestReloadLogger(t *testing.T) {
+	restoreGlobalLogger(t)
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+
+	if err := ReloadLogger(Options{Level: "debug", OutputPaths: []string{first}}); err != nil {
+		t.Fatal(err)
+	}
+	cloned := CloneLogger("cloned")
+	Info(nil, "before reload")
+	if !strings.Contains(readLogFile(t, first), "before reload") {
+		t.Fatal("log should write to first file")
+	}
+
+	err := ReloadLogger(Options{
+		Level:         "info",
+		Format:        "console",
+		OutputPaths:   []string{second},
+		InitialFields: map[string]interface{}{"reloaded": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloned.Info("cloned after reload")
+	Debug(nil, "debug after reload")
+	Info(nil, "global after reload")
+
+	content := readLogFile(t, second)
+	if !strings.Contains(content, "cloned after reload") || !strings.Contains(content, "global after reload") {
+		t.Fatal("derived and global logger should write to second file:", content)
+	}
+	if !strings.Contains(content, "reloaded") || strings.HasPrefix(content, "{") {
+		t.Fatal("reloaded logger should use new format and initial fields:", content)
+	}
+	if strings.Contains(content, "debug after reload") {
+		t.Fatal("reloaded level should be info")
+	}
+	if strings.Contains(readLogFile(t, first), "after reload") {
+		t.Fatal("first file should not be written after reload")
+	}
+	// 派生的 logger 的 ErrorOutput 也使用新的日志输出
+	cloned.WithOptions(zap.AddCallerSkip(1000)).Info("caller error after reload")
+	if content := readLogFile(t, second); !strings.Contains(content, "failed to get caller") {
+		t.Fatal("derived logger error output should write to second file:", content)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	restoreGlobalLogger(t)
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+	path := filepath.Join(dir, "logging.yaml")
+	if err := os.WriteFile(path, []byte("output_paths: ["+first+"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	options, err := LoadOptions(path, "LOGGING_TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadLogger(options); err != nil {
+		t.Fatal(err)
+	}
+	stop, err := WatchConfig(path, "LOGGING_TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	waitLog := func(file, msg string) {
+		for i := 0; i < 50; i++ {
+			Info(nil, msg)
+			if strings.Contains(readLogFile(t, file), msg) {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("%s not found in %s", msg, file)
+	}
+
+	if err := os.WriteFile(path, []byte("output_paths: ["+second+"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitLog(second, "file changed")
+
+	// 配置不合法时继续使用当前的 logger
+	if err := os.WriteFile(path, []byte("level: verbose\noutput_paths: ["+first+"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(3 * reloadDebounce)
+	waitLog(second, "invalid config")
+
+	// 环境变量变化不会触发文件变化，发送 SIGHUP 后重新加载，环境变量优先级高于配置文件
+	third := filepath.Join(dir, "third.log")
+	t.Setenv("LOGGING_TEST_LEVEL", "debug")
+	t.Setenv("LOGGING_TEST_OUTPUT_PATHS", third)
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	waitLog(third, "sighup")
+}
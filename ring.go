@@ -0,0 +1,400 @@
+// 内存中保存最近日志的 core
+// RingCore 使用无锁的环形缓冲区保存最近的日志，数量和字节数超过限制时丢弃最早的日志，
+// 可以通过 Options.Ring 添加到 logger 中，与日志输出一样经过脱敏和采样，通过 http 接口按条件查询或使用 SSE 实时查看
+
+package logging
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// 默认保存的日志数量
+	defaultRingSize = 2000
+	// 默认保存的日志字节数
+	defaultRingMaxBytes = 4 << 20
+	// 订阅者 channel 的缓冲大小，订阅者处理不及时时丢弃日志
+	ringSubscriberBuffer = 256
+	// SSE 保持连接的注释发送间隔
+	ringSSEKeepAlive = 15 * time.Second
+)
+
+// RingCoreOption RingCore 配置
+type RingCoreOption struct {
+	Size     int                  // 最多保存的日志数量，默认 2000
+	MaxBytes int                  // 最多保存的日志字节数（按日志内容和字段估算），默认 4MB
+	Level    zapcore.LevelEnabler // 保存的日志级别，默认全部保存
+}
+
+// LogQuery 查询最近日志的条件，为零值的条件不过滤
+type LogQuery struct {
+	Level      zapcore.LevelEnabler // 最低日志级别，如 zapcore.WarnLevel
+	LoggerName string               // logger 名称，以 .* 结尾时匹配该名称及其下所有名称
+	TraceID    string               // trace id
+	Since      time.Time            // 开始时间
+	Until      time.Time            // 结束时间
+	Limit      int                  // 返回最近的条数
+}
+
+// match 日志是否满足查询条件
+func (q LogQuery) match(entry *LogEntry, level zapcore.Level) bool {
+	if q.Level != nil && !q.Level.Enabled(level) {
+		return false
+	}
+	if q.LoggerName != "" && !matchLoggerName(q.LoggerName, entry.LoggerName) {
+		return false
+	}
+	if q.TraceID != "" && fmt.Sprint(entry.Fields[string(TraceIDKeyname)]) != q.TraceID {
+		return false
+	}
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && entry.Time.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// ringEntry 环形缓冲区中的一条日志
+type ringEntry struct {
+	seq   uint64
+	size  int64
+	level zapcore.Level
+	entry LogEntry
+}
+
+// ringSubscriber 实时日志的订阅者
+type ringSubscriber struct {
+	query LogQuery
+	ch    chan LogEntry
+}
+
+// ringBuffer 环形缓冲区，写入时不加锁
+type ringBuffer struct {
+	slots    []atomic.Pointer[ringEntry]
+	maxBytes int64
+	head     atomic.Uint64 // 下一条日志的序号
+	tail     atomic.Uint64 // 最早的有效日志的序号
+	bytes    atomic.Int64
+
+	subscribersMutex sync.Mutex
+	subscribers      atomic.Pointer[[]*ringSubscriber]
+}
+
+// RingCore 在内存中保存最近日志的 core
+type RingCore struct {
+	buf    *ringBuffer
+	level  zapcore.LevelEnabler
+	fields []zapcore.Field
+}
+
+// NewRingCore 创建保存最近日志的 core
+// logger, err := logging.NewLogger(logging.Options{Ring: ring})
+func NewRingCore(option RingCoreOption) *RingCore {
+	if option.Size <= 0 {
+		option.Size = defaultRingSize
+	}
+	if option.MaxBytes <= 0 {
+		option.MaxBytes = defaultRingMaxBytes
+	}
+	if option.Level == nil {
+		option.Level = zapcore.DebugLevel
+	}
+	buf := &ringBuffer{
+		slots:    make([]atomic.Pointer[ringEntry], option.Size),
+		maxBytes: int64(option.MaxBytes),
+	}
+	buf.subscribers.Store(&[]*ringSubscriber{})
+	return &RingCore{buf: buf, level: option.Level}
+}
+
+// Enabled zap core interface
+func (c *RingCore) Enabled(lvl zapcore.Level) bool {
+	return c.level.Enabled(lvl)
+}
+
+// With zap core interface ，与原 core 共享缓冲区
+func (c *RingCore) With(fields []zapcore.Field) zapcore.Core {
+	merged := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	merged = append(merged, c.fields...)
+	merged = append(merged, fields...)
+	return &RingCore{buf: c.buf, level: c.level, fields: merged}
+}
+
+// Check zap core interface
+func (c *RingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write zap core interface
+// 被 tee 等不调用 Check 的 core 直接写入时同样只保存满足日志级别的日志
+func (c *RingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.Enabled(ent.Level) {
+		return nil
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	entry := LogEntry{
+		Time:       ent.Time,
+		Level:      ent.Level.String(),
+		LoggerName: ent.LoggerName,
+		Message:    ent.Message,
+		Stack:      ent.Stack,
+		Fields:     enc.Fields,
+	}
+	if ent.Caller.Defined {
+		entry.Caller = ent.Caller.TrimmedPath()
+	}
+	size := int64(len(entry.Level) + len(entry.LoggerName) + len(entry.Message) + len(entry.Caller) + len(entry.Stack) + ringValueSize(entry.Fields))
+	c.buf.put(&ringEntry{size: size, level: ent.Level, entry: entry})
+	return nil
+}
+
+// Sync zap core interface
+func (c *RingCore) Sync() error {
+	return nil
+}
+
+// ringValueSize 估算字段值占用的字节数，避免每条日志都进行 JSON 编码
+func ringValueSize(value interface{}) int {
+	switch v := value.(type) {
+	case nil, bool:
+		return 5
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case map[string]interface{}:
+		size := 0
+		for k, item := range v {
+			size += len(k) + ringValueSize(item)
+		}
+		return size
+	case []interface{}:
+		size := 0
+		for _, item := range v {
+			size += ringValueSize(item)
+		}
+		return size
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr, float32, float64, complex64, complex128, time.Time, time.Duration:
+		return 16
+	}
+	return len(fmt.Sprint(value))
+}
+
+// put 写入日志，超过数量或字节数限制时丢弃最早的日志
+func (b *ringBuffer) put(e *ringEntry) {
+	size := uint64(len(b.slots))
+	e.seq = b.head.Add(1) - 1
+	if old := b.slots[e.seq%size].Swap(e); old != nil {
+		b.bytes.Add(-old.size)
+	}
+	b.bytes.Add(e.size)
+	// 保证 tail 不落后于环形缓冲区的范围
+	for {
+		tail := b.tail.Load()
+		if e.seq < size || tail > e.seq-size || b.tail.CompareAndSwap(tail, e.seq-size+1) {
+			break
+		}
+	}
+	// 超过字节数限制时从最早的日志开始丢弃，至少保留最新的一条
+	for b.bytes.Load() > b.maxBytes {
+		tail := b.tail.Load()
+		if tail >= e.seq {
+			break
+		}
+		if !b.tail.CompareAndSwap(tail, tail+1) {
+			continue
+		}
+		slot := &b.slots[tail%size]
+		if old := slot.Load(); old != nil && old.seq == tail && slot.CompareAndSwap(old, nil) {
+			b.bytes.Add(-old.size)
+		}
+	}
+
+	for _, sub := range *b.subscribers.Load() {
+		if sub.query.match(&e.entry, e.level) {
+			select {
+			case sub.ch <- e.entry:
+			default:
+			}
+		}
+	}
+}
+
+// Query 按条件查询最近的日志，按时间顺序排列
+func (c *RingCore) Query(q LogQuery) []LogEntry {
+	b := c.buf
+	size := uint64(len(b.slots))
+	head := b.head.Load()
+	start := b.tail.Load()
+	if head > size && start < head-size {
+		start = head - size
+	}
+	entries := []LogEntry{}
+	for seq := start; seq < head; seq++ {
+		e := b.slots[seq%size].Load()
+		if e == nil || e.seq != seq || !q.match(&e.entry, e.level) {
+			continue
+		}
+		entries = append(entries, e.entry)
+	}
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[len(entries)-q.Limit:]
+	}
+	return entries
+}
+
+// RecentLogs 返回最近的 limit 条日志，实现 RecentLogsSource 接口
+func (c *RingCore) RecentLogs(limit int) []LogEntry {
+	return c.Query(LogQuery{Limit: limit})
+}
+
+// Subscribe 订阅满足条件的新日志，返回接收日志的 channel 和取消订阅的函数
+// 订阅者处理不及时时会丢弃日志
+func (c *RingCore) Subscribe(q LogQuery) (<-chan LogEntry, func()) {
+	b := c.buf
+	sub := &ringSubscriber{query: q, ch: make(chan LogEntry, ringSubscriberBuffer)}
+	b.subscribersMutex.Lock()
+	subs := append(append([]*ringSubscriber{}, *b.subscribers.Load()...), sub)
+	b.subscribers.Store(&subs)
+	b.subscribersMutex.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.subscribersMutex.Lock()
+			defer b.subscribersMutex.Unlock()
+			subs := make([]*ringSubscriber, 0, len(*b.subscribers.Load()))
+			for _, s := range *b.subscribers.Load() {
+				if s != sub {
+					subs = append(subs, s)
+				}
+			}
+			b.subscribers.Store(&subs)
+		})
+	}
+	return sub.ch, cancel
+}
+
+// ServeHTTP 查询最近的日志， stream=true 时使用 SSE 实时推送新日志
+// curl 'http://host:port/logs?level=warn&logger=logging.gin.*&trace_id=xxx&since=10m&limit=100'
+// curl 'http://host:port/logs?stream=true&level=error'
+func (c *RingCore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q, err := parseLogQuery(r)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		jsoniter.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if stream, _ := strconv.ParseBool(r.URL.Query().Get("stream")); !stream {
+		w.Header().Set("Content-Type", "application/json")
+		jsoniter.NewEncoder(w).Encode(c.Query(q))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	ch, cancel := c.Subscribe(LogQuery{Level: q.Level, LoggerName: q.LoggerName, TraceID: q.TraceID})
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	// 指定了 limit 时先推送满足条件的最近日志
+	if q.Limit > 0 {
+		for _, entry := range c.Query(q) {
+			writeSSE(w, entry)
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(ringSSEKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case entry := <-ch:
+			writeSSE(w, entry)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeSSE 以 SSE 格式写入一条日志
+func writeSSE(w http.ResponseWriter, entry LogEntry) {
+	b, err := jsoniter.Marshal(entry)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "data: %s\n\n", b)
+}
+
+// parseLogQuery 从请求参数中解析查询条件， since 和 until 支持 RFC3339 时间或距今的时长
+func parseLogQuery(r *http.Request) (LogQuery, error) {
+	params := r.URL.Query()
+	q := LogQuery{
+		LoggerName: params.Get("logger"),
+		TraceID:    params.Get(string(TraceIDKeyname)),
+	}
+	if lvl := params.Get("level"); lvl != "" {
+		level, exists := ZapcoreLevelMap[strings.ToLower(lvl)]
+		if !exists {
+			return q, fmt.Errorf("invalid level: %s", lvl)
+		}
+		q.Level = level
+	}
+	parseTime := func(key string) (time.Time, error) {
+		value := params.Get(key)
+		if value == "" {
+			return time.Time{}, nil
+		}
+		if d, err := time.ParseDuration(value); err == nil {
+			return time.Now().Add(-d), nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return t, fmt.Errorf("invalid %s: %s", key, value)
+		}
+		return t, nil
+	}
+	var err error
+	if q.Since, err = parseTime("since"); err != nil {
+		return q, err
+	}
+	if q.Until, err = parseTime("until"); err != nil {
+		return q, err
+	}
+	if limit := params.Get("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			return q, fmt.Errorf("invalid limit: %s", limit)
+		}
+	}
+	return q, nil
+}
@@ -0,0 +1,171 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRingCore(t *testing.T) {
+	ring := NewRingCore(RingCoreOption{Size: 3})
+	logger := zap.New(ring).Named("ring")
+	logger.Debug("0")
+	logger.Info("1")
+	logger.Named("sub").Warn("2", zap.String(string(TraceIDKeyname), "trace"))
+	logger.With(zap.Int("n", 3)).Error("3")
+
+	entries := ring.RecentLogs(0)
+	if len(entries) != 3 || entries[0].Message != "1" || entries[2].Message != "3" {
+		t.Fatal("ring should keep the latest 3 entries", entries)
+	}
+	if entries[2].Fields["n"] != int64(3) {
+		t.Fatal("ring should keep context fields", entries[2].Fields)
+	}
+	if entries := ring.RecentLogs(1); len(entries) != 1 || entries[0].Message != "3" {
+		t.Fatal("limit should return the latest entries", entries)
+	}
+	if entries := ring.Query(LogQuery{Level: zap.WarnLevel}); len(entries) != 2 {
+		t.Fatal("query by level failed", entries)
+	}
+	if entries := ring.Query(LogQuery{LoggerName: "ring.*"}); len(entries) != 3 {
+		t.Fatal("query by logger name prefix failed", entries)
+	}
+	if entries := ring.Query(LogQuery{LoggerName: "ring.sub"}); len(entries) != 1 || entries[0].Message != "2" {
+		t.Fatal("query by logger name failed", entries)
+	}
+	if entries := ring.Query(LogQuery{TraceID: "trace"}); len(entries) != 1 || entries[0].Message != "2" {
+		t.Fatal("query by trace id failed", entries)
+	}
+	if entries := ring.Query(LogQuery{Since: time.Now().Add(time.Hour)}); len(entries) != 0 {
+		t.Fatal("query by since failed", entries)
+	}
+
+	// 没有指定日志级别时返回 debug 日志
+	logger.Debug("4")
+	if entries := ring.RecentLogs(1); len(entries) != 1 || entries[0].Message != "4" {
+		t.Fatal("recent logs should include debug entries", entries)
+	}
+	if entries := ring.Query(LogQuery{}); len(entries) != 3 || entries[2].Message != "4" {
+		t.Fatal("zero query should include debug entries", entries)
+	}
+}
+
+func TestRingCoreRedact(t *testing.T) {
+	ring := NewRingCore(RingCoreOption{})
+	logger, err := NewLogger(Options{OutputPaths: []string{filepath.Join(t.TempDir(), "ring.log")}, Ring: ring, Redactor: DefaultRedactor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("login", zap.String("password", "p@ss"))
+	entries := ring.RecentLogs(0)
+	if len(entries) != 1 || entries[0].Fields["password"] != RedactMaskString {
+		t.Fatal("ring should keep redacted fields", entries)
+	}
+}
+
+func TestRingCoreLevel(t *testing.T) {
+	ring := NewRingCore(RingCoreOption{Level: zap.WarnLevel})
+	logger, handle, err := NewLoggerWithHandle(Options{
+		OutputPaths:       []string{filepath.Join(t.TempDir(), "ring.log")},
+		Ring:              ring,
+		Redactor:          DefaultRedactor,
+		AtomicLevelServer: AtomicLevelServerOption{Addr: "127.0.0.1:0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close(context.Background())
+	logger.Info("info")
+	logger.Warn("warn")
+	// 设置了脱敏时 ring 同样只保存满足日志级别的日志
+	if entries := ring.RecentLogs(0); len(entries) != 1 || entries[0].Message != "warn" {
+		t.Fatal("ring should only keep entries enabled by its level", entries)
+	}
+	ring.Write(zapcore.Entry{Level: zap.InfoLevel, Message: "direct"}, nil)
+	if entries := ring.RecentLogs(0); len(entries) != 1 {
+		t.Fatal("write should check the ring level", entries)
+	}
+
+	// AtomicLevel 服务的 /logs 接口查询 Options.Ring 中的日志
+	rsp, err := http.Get("http://" + handle.server.Addr().String() + "/logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	var entries []LogEntry
+	if err := jsoniter.NewDecoder(rsp.Body).Decode(&entries); err != nil || rsp.StatusCode != http.StatusOK || len(entries) != 1 || entries[0].Message != "warn" {
+		t.Fatal("atomic level server should serve the ring logs", rsp.StatusCode, entries, err)
+	}
+}
+
+func TestRingCoreMaxBytes(t *testing.T) {
+	ring := NewRingCore(RingCoreOption{Size: 100, MaxBytes: 500})
+	logger := zap.New(ring)
+	for i := 0; i < 50; i++ {
+		logger.Info(strings.Repeat("x", 100))
+	}
+	entries := ring.RecentLogs(0)
+	if len(entries) == 0 || len(entries) > 5 {
+		t.Fatal("ring should be bounded by bytes", len(entries))
+	}
+	if ring.buf.bytes.Load() > 500 {
+		t.Fatal("ring bytes exceed max bytes", ring.buf.bytes.Load())
+	}
+}
+
+func TestRingCoreServeHTTP(t *testing.T) {
+	ring := NewRingCore(RingCoreOption{})
+	logger := zap.New(ring).Named("http")
+	logger.Info("info")
+	logger.Error("error")
+
+	w := httptest.NewRecorder()
+	ring.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logs?level=error&since=1m", nil))
+	var entries []LogEntry
+	if err := jsoniter.Unmarshal(w.Body.Bytes(), &entries); err != nil || len(entries) != 1 || entries[0].Message != "error" {
+		t.Fatal("query logs failed", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	ring.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logs?level=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatal("invalid level should be bad request", w.Code)
+	}
+	logger.Debug("debug")
+	w = httptest.NewRecorder()
+	ring.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logs?limit=1", nil))
+	if err := jsoniter.Unmarshal(w.Body.Bytes(), &entries); err != nil || len(entries) != 1 || entries[0].Message != "debug" {
+		t.Fatal("query without level should return debug logs", w.Body.String())
+	}
+
+	server := httptest.NewServer(ring)
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?stream=true&level=warn", nil)
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	if rsp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal("stream should use SSE", rsp.Header.Get("Content-Type"))
+	}
+	logger.Info("ignored")
+	logger.Warn("streamed")
+	line, err := bufio.NewReader(rsp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "data: ") || !strings.Contains(line, "streamed") {
+		t.Fatal("invalid SSE event", line)
+	}
+}
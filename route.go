@@ -0,0 +1,136 @@
+// 日志路由
+// 按日志级别和 logger 名称将日志写入不同的日志输出，每个路由可以使用不同的日志格式
+// 例如 error 及以上级别的日志单独写入一个文件， gin 的访问日志写入 access log 文件
+
+package logging
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Route 日志路由规则，满足条件的日志会同时写入该路由的日志输出
+type Route struct {
+	LoggerNames    []string        // 匹配的 logger 名称，以 .* 结尾时匹配该名称及其下所有名称，以 *. 开头时匹配以该名称结尾的名称，为空时匹配所有 logger
+	Level          string          // 最低日志级别，为空时不限制
+	Format         string          // 日志格式 json, console ，为空时使用 Options.Format
+	OutputPaths    []string        // 日志输出位置
+	LumberjackSink *LumberjackSink // 该路由使用的 lumberjack sink ，需要使用与 Options.LumberjackSink 不同的 scheme
+	Exclusive      bool            // 匹配的日志是否不再写入 Options.OutputPaths
+}
+
+// routeMatcher 解析后的路由匹配条件
+type routeMatcher struct {
+	names []string
+	level zapcore.Level
+}
+
+// newRouteMatcher 解析路由的匹配条件
+func newRouteMatcher(route Route) (routeMatcher, error) {
+	m := routeMatcher{names: route.LoggerNames, level: zapcore.DebugLevel}
+	if route.Level != "" {
+		level, exists := ZapcoreLevelMap[strings.ToLower(route.Level)]
+		if !exists {
+			return m, fmt.Errorf("invalid route level: %s", route.Level)
+		}
+		m.level = level
+	}
+	return m, nil
+}
+
+// match 日志是否满足路由的条件
+func (m routeMatcher) match(ent zapcore.Entry) bool {
+	if ent.Level < m.level {
+		return false
+	}
+	if len(m.names) == 0 {
+		return true
+	}
+	for _, name := range m.names {
+		if matchLoggerName(name, ent.LoggerName) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeCore 只写入满足条件的日志的 core
+type routeCore struct {
+	zapcore.Core
+	match func(zapcore.Entry) bool
+}
+
+// With zap core interface
+func (c *routeCore) With(fields []zapcore.Field) zapcore.Core {
+	return &routeCore{Core: c.Core.With(fields), match: c.match}
+}
+
+// Check zap core interface
+func (c *routeCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.match(ent) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// newRoutedCore 为每个路由创建写入其日志输出的 core ，与 core 组成 tee ，返回关闭路由日志输出的函数
+// 有 Exclusive 的路由时， core 不再写入满足这些路由条件的日志
+func newRoutedCore(core zapcore.Core, routes []Route, format string, encoderConfig zapcore.EncoderConfig, async *AsyncOption) (zapcore.Core, func(), error) {
+	var closers []func()
+	closer := func() {
+		for _, closeOut := range closers {
+			closeOut()
+		}
+	}
+	cores := make([]zapcore.Core, 0, len(routes)+1)
+	var exclusive []routeMatcher
+	for i, route := range routes {
+		matcher, err := newRouteMatcher(route)
+		if err != nil {
+			closer()
+			return nil, nil, err
+		}
+		if len(route.OutputPaths) == 0 {
+			closer()
+			return nil, nil, fmt.Errorf("route %d output paths is required", i)
+		}
+		if route.LumberjackSink != nil {
+			if err := RegisterLumberjackSink(route.LumberjackSink); err != nil {
+				Error(nil, "RegisterSink error", zap.Error(err))
+			}
+		}
+		sink, closeOut, err := openOutput(route.OutputPaths, async)
+		if err != nil {
+			closer()
+			return nil, nil, err
+		}
+		closers = append(closers, closeOut)
+		if route.Format == "" {
+			route.Format = format
+		}
+		cores = append(cores, &routeCore{
+			Core:  zapcore.NewCore(newEncoder(route.Format, encoderConfig), sink, zap.DebugLevel),
+			match: matcher.match,
+		})
+		if route.Exclusive {
+			exclusive = append(exclusive, matcher)
+		}
+	}
+	if len(cores) == 0 {
+		return core, closer, nil
+	}
+	if len(exclusive) > 0 {
+		core = &routeCore{Core: core, match: func(ent zapcore.Entry) bool {
+			for _, m := range exclusive {
+				if m.match(ent) {
+					return false
+				}
+			}
+			return true
+		}}
+	}
+	return zapcore.NewTee(append([]zapcore.Core{core}, cores...)...), closer, nil
+}
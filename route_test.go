@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRoutes(t *testing.T) {
+	dir := t.TempDir()
+	mainPath := filepath.Join(dir, "main.log")
+	errorPath := filepath.Join(dir, "error.log")
+	accessPath := filepath.Join(dir, "access.log")
+	logger, closer, err := buildLogger(Options{
+		Name:        "route",
+		OutputPaths: []string{mainPath},
+		Routes: []Route{
+			{Level: "error", OutputPaths: []string{errorPath}, Format: "console"},
+			{LoggerNames: []string{"route.gin.access_logger.*", "*.gorm"}, OutputPaths: []string{accessPath}, Exclusive: true},
+		},
+	}, zap.NewAtomicLevelAt(zap.DebugLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger = logger.With(zap.String("k", "v"))
+	logger.Info("main info")
+	logger.Error("main error")
+	access := logger.Named("gin").Named("access_logger")
+	access.Info("access info")
+	access.Named("details").Error("access error")
+	logger.Named("ctx_logger").Named("gorm").Info("access sql")
+	closer()
+
+	mainContent := readLogFile(t, mainPath)
+	if !strings.Contains(mainContent, "main info") || !strings.Contains(mainContent, "main error") || strings.Contains(mainContent, "access") {
+		t.Fatal("main output should not contain exclusive route logs:", mainContent)
+	}
+	errorContent := readLogFile(t, errorPath)
+	if strings.Contains(errorContent, "info") || !strings.Contains(errorContent, "main error") || !strings.Contains(errorContent, "access error") {
+		t.Fatal("error route should only contain error logs:", errorContent)
+	}
+	if strings.HasPrefix(errorContent, "{") || !strings.Contains(errorContent, `"k": "v"`) {
+		t.Fatal("error route should use console format with fields:", errorContent)
+	}
+	accessContent := readLogFile(t, accessPath)
+	if !strings.Contains(accessContent, "access info") || !strings.Contains(accessContent, "access error") || !strings.Contains(accessContent, "access sql") || strings.Contains(accessContent, "main") {
+		t.Fatal("access route should only contain access logs:", accessContent)
+	}
+
+	if _, _, err := buildLogger(Options{Routes: []Route{{Level: "x", OutputPaths: []string{"stdout"}}}}, zap.NewAtomicLevel()); err == nil {
+		t.Fatal("invalid route level should return error")
+	}
+	if _, _, err := buildLogger(Options{Routes: []Route{{}}}, zap.NewAtomicLevel()); err == nil {
+		t.Fatal("route without output paths should return error")
+	}
+}
+
+func TestRoutesRedact(t *testing.T) {
+	dir := t.TempDir()
+	mainPath := filepath.Join(dir, "main.log")
+	errorPath := filepath.Join(dir, "error.log")
+	accessPath := filepath.Join(dir, "access.log")
+	logger, closer, err := buildLogger(Options{
+		Name:        "route",
+		OutputPaths: []string{mainPath},
+		Routes: []Route{
+			{Level: "error", OutputPaths: []string{errorPath}},
+			{LoggerNames: []string{"route.access"}, OutputPaths: []string{accessPath}, Exclusive: true},
+		},
+		Redactor: DefaultRedactor,
+	}, zap.NewAtomicLevelAt(zap.DebugLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("info-line", zap.String("password", "p@ss"))
+	logger.Error("error-line", zap.String("password", "p@ss"))
+	logger.Named("access").Info("access-line")
+	closer()
+
+	// 设置脱敏后仍然按路由的日志级别、 logger 名称和 Exclusive 写入
+	mainContent := readLogFile(t, mainPath)
+	if !strings.Contains(mainContent, "info-line") || !strings.Contains(mainContent, "error-line") || strings.Contains(mainContent, "access-line") || strings.Contains(mainContent, "p@ss") {
+		t.Fatal("main output should be redacted and not contain exclusive route logs:", mainContent)
+	}
+	errorContent := readLogFile(t, errorPath)
+	if strings.Contains(errorContent, "info-line") || strings.Contains(errorContent, "access-line") || !strings.Contains(errorContent, "error-line") || strings.Contains(errorContent, "p@ss") {
+		t.Fatal("error route should only contain redacted error logs:", errorContent)
+	}
+	accessContent := readLogFile(t, accessPath)
+	if !strings.Contains(accessContent, "access-line") || strings.Contains(accessContent, "info-line") {
+		t.Fatal("access route should only contain access logs:", accessContent)
+	}
+}
@@ -0,0 +1,204 @@
+// 日志采样
+// Sampling 实现了日志的流控功能，在 Tick 的时间单位内，如果某个日志级别下同样内容的日志输出数量超过了 Initial 的数量，
+// 那么超过之后，每隔 Thereafter 的数量，才会再输出一次。是一个对日志输出的保护功能。
+// 与 zap 自带的 sampler 相比，支持按日志级别设置不同的采样规则
+
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	promSamplingDroppedCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "sampling_dropped_count",
+			Help:      "log entries dropped by sampling",
+		}, []string{"level"},
+	)
+)
+
+const (
+	// 默认采样配置
+	defaultSamplingTick       = time.Second
+	defaultSamplingInitial    = 100
+	defaultSamplingThereafter = 100
+
+	// 采样计数的 hash 桶数量
+	samplingCounterSize = 4096
+)
+
+// SamplingOption 日志采样配置
+type SamplingOption struct {
+	Disable              bool                           // 是否关闭采样
+	Tick                 time.Duration                  // 采样的时间单位，默认 1s
+	Initial              int                            // 时间单位内同级别同内容的日志前 Initial 条全部输出，为 0 时使用默认值 100
+	Thereafter           int                            // 超过 Initial 后每 Thereafter 条输出一次，为 0 时使用默认值 100
+	Levels               map[string]SamplingLevelOption // 按日志级别设置采样规则， key 为 debug, info, warn, error, dpanic, panic, fatal
+	DisableErrorAndAbove bool                           // Error 及以上级别的日志是否不采样，优先级低于 Levels
+	// 采样结果回调，丢弃的日志会同时记录到 prometheus 指标中
+	Hook func(zapcore.Entry, zapcore.SamplingDecision)
+}
+
+// SamplingLevelOption 指定日志级别的采样规则
+type SamplingLevelOption struct {
+	Disable    bool `json:"disable" yaml:"disable" toml:"disable"`          // 该级别是否不采样
+	Initial    int  `json:"initial" yaml:"initial" toml:"initial"`          // 为 0 时使用 SamplingOption.Initial
+	Thereafter int  `json:"thereafter" yaml:"thereafter" toml:"thereafter"` // 为 0 时使用 SamplingOption.Thereafter
+}
+
+// samplingRule 解析后的采样规则
+type samplingRule struct {
+	initial    uint64
+	thereafter uint64
+}
+
+// samplingCore 按日志级别采样的 core
+type samplingCore struct {
+	zapcore.Core
+	tick     time.Duration
+	rules    map[zapcore.Level]*samplingRule
+	counters map[zapcore.Level]*[samplingCounterSize]samplingCounter
+	hook     func(zapcore.Entry, zapcore.SamplingDecision)
+}
+
+// NewSamplingCore 根据采样配置创建 core ， option 为 nil 时使用默认配置，配置了 Disable 时直接返回 core
+func NewSamplingCore(core zapcore.Core, option *SamplingOption) (zapcore.Core, error) {
+	if option == nil {
+		option = &SamplingOption{}
+	}
+	if option.Disable {
+		return core, nil
+	}
+	tick := option.Tick
+	if tick <= 0 {
+		tick = defaultSamplingTick
+	}
+	if option.Initial < 0 || option.Thereafter < 0 {
+		return nil, fmt.Errorf("invalid sampling option: initial and thereafter must not be negative")
+	}
+	initial, thereafter := option.Initial, option.Thereafter
+	if initial == 0 {
+		initial = defaultSamplingInitial
+	}
+	if thereafter == 0 {
+		thereafter = defaultSamplingThereafter
+	}
+
+	s := &samplingCore{
+		Core:     core,
+		tick:     tick,
+		rules:    make(map[zapcore.Level]*samplingRule),
+		counters: make(map[zapcore.Level]*[samplingCounterSize]samplingCounter),
+		hook:     option.Hook,
+	}
+	for lvl := zapcore.DebugLevel; lvl <= zapcore.FatalLevel; lvl++ {
+		if option.DisableErrorAndAbove && lvl >= zapcore.ErrorLevel {
+			continue
+		}
+		s.rules[lvl] = &samplingRule{initial: uint64(initial), thereafter: uint64(thereafter)}
+	}
+	for name, levelOption := range option.Levels {
+		lvl, exists := ZapcoreLevelMap[strings.ToLower(name)]
+		if !exists {
+			return nil, fmt.Errorf("invalid sampling level: %s", name)
+		}
+		if levelOption.Initial < 0 || levelOption.Thereafter < 0 {
+			return nil, fmt.Errorf("invalid sampling option for level %s: initial and thereafter must not be negative", name)
+		}
+		if levelOption.Disable {
+			delete(s.rules, lvl)
+			continue
+		}
+		rule := &samplingRule{initial: uint64(initial), thereafter: uint64(thereafter)}
+		if levelOption.Initial > 0 {
+			rule.initial = uint64(levelOption.Initial)
+		}
+		if levelOption.Thereafter > 0 {
+			rule.thereafter = uint64(levelOption.Thereafter)
+		}
+		s.rules[lvl] = rule
+	}
+	for lvl := range s.rules {
+		s.counters[lvl] = &[samplingCounterSize]samplingCounter{}
+	}
+	return s, nil
+}
+
+// With zap core interface ，共享采样计数
+func (s *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &samplingCore{
+		Core:     s.Core.With(fields),
+		tick:     s.tick,
+		rules:    s.rules,
+		counters: s.counters,
+		hook:     s.hook,
+	}
+}
+
+// Check zap core interface
+func (s *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !s.Enabled(ent.Level) {
+		return ce
+	}
+	rule, exists := s.rules[ent.Level]
+	if !exists {
+		return s.Core.Check(ent, ce)
+	}
+	counter := &s.counters[ent.Level][fnv32a(ent.Message)%samplingCounterSize]
+	n := counter.incCheckReset(ent.Time, s.tick)
+	if n > rule.initial && (n-rule.initial)%rule.thereafter != 0 {
+		promSamplingDroppedCount.WithLabelValues(ent.Level.String()).Inc()
+		if s.hook != nil {
+			s.hook(ent, zapcore.LogDropped)
+		}
+		return ce
+	}
+	if s.hook != nil {
+		s.hook(ent, zapcore.LogSampled)
+	}
+	return s.Core.Check(ent, ce)
+}
+
+// samplingCounter 在 tick 时间内的计数
+type samplingCounter struct {
+	resetAt atomic.Int64
+	counter atomic.Uint64
+}
+
+// incCheckReset 计数加一，超过 tick 时间后重置计数
+func (c *samplingCounter) incCheckReset(t time.Time, tick time.Duration) uint64 {
+	tn := t.UnixNano()
+	resetAfter := c.resetAt.Load()
+	if resetAfter > tn {
+		return c.counter.Add(1)
+	}
+	c.counter.Store(1)
+	if !c.resetAt.CompareAndSwap(resetAfter, tn+tick.Nanoseconds()) {
+		// 其他 goroutine 已经重置了计数
+		return c.counter.Add(1)
+	}
+	return 1
+}
+
+// fnv32a 计算字符串的 FNV-1a hash
+func fnv32a(s string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	hash := uint32(offset32)
+	for i := 0; i < len(s); i++ {
+		hash ^= uint32(s[i])
+		hash *= prime32
+	}
+	return hash
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSamplingCore(t *testing.T) {
+	obsCore, logs := observer.New(zap.DebugLevel)
+	dropped := map[zapcore.Level]int{}
+	core, err := NewSamplingCore(obsCore, &SamplingOption{
+		Initial:              2,
+		Thereafter:           3,
+		DisableErrorAndAbove: true,
+		Levels: map[string]SamplingLevelOption{
+			"warn":  {Disable: true},
+			"DEBUG": {Initial: 1},
+		},
+		Hook: func(ent zapcore.Entry, dec zapcore.SamplingDecision) {
+			if dec&zapcore.LogDropped > 0 {
+				dropped[ent.Level]++
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(core).With(zap.String("k", "v"))
+	for i := 0; i < 10; i++ {
+		l.Debug("debug")
+		l.Info("info")
+		l.Warn("warn")
+		l.Error("error")
+	}
+	// debug: 第 1 条，之后第 4 、 7 、 10 条； info: 前 2 条，之后第 5 、 8 条
+	expects := map[zapcore.Level]int{
+		zap.DebugLevel: 4,
+		zap.InfoLevel:  4,
+		zap.WarnLevel:  10,
+		zap.ErrorLevel: 10,
+	}
+	for lvl, expect := range expects {
+		if n := logs.FilterLevelExact(lvl).Len(); n != expect {
+			t.Error(lvl, "should log", expect, "got", n)
+		}
+		if dropped[lvl] != 10-expect {
+			t.Error(lvl, "should drop", 10-expect, "got", dropped[lvl])
+		}
+	}
+}
+
+func TestSamplingCoreOption(t *testing.T) {
+	obsCore, _ := observer.New(zap.DebugLevel)
+	if core, err := NewSamplingCore(obsCore, &SamplingOption{Disable: true}); err != nil || core != obsCore {
+		t.Error("disable sampling should return the origin core", err)
+	}
+	if _, err := NewSamplingCore(obsCore, &SamplingOption{Levels: map[string]SamplingLevelOption{"unknown": {}}}); err == nil {
+		t.Error("invalid level should return error")
+	}
+	if _, err := NewSamplingCore(obsCore, &SamplingOption{Initial: -1}); err == nil {
+		t.Error("negative initial should return error")
+	}
+	if _, err := NewLogger(Options{Sampling: &SamplingOption{Thereafter: -1}}); err == nil {
+		t.Error("NewLogger should return sampling option error")
+	}
+
+	// Thereafter 为 0 时与级别规则相同，使用默认值而不是丢弃 Initial 之后的全部日志
+	obsCore, logs := observer.New(zap.DebugLevel)
+	core, err := NewSamplingCore(obsCore, &SamplingOption{Initial: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(core)
+	for i := 0; i < 2+defaultSamplingThereafter; i++ {
+		l.Info("info")
+	}
+	if n := logs.Len(); n != 3 {
+		t.Error("thereafter 0 should use the default value, logged", n)
+	}
+}
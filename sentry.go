@@ -168,6 +168,11 @@ func NewSentryClient(options sentry.ClientOptions) (*sentry.Client, error) {
 
 // SentryAttach attach sentrycore
 func SentryAttach(l *zap.Logger, sentryClient *sentry.Client) *zap.Logger {
+	return AttachCore(l, newDefaultSentryCore(sentryClient, nil))
+}
+
+// newDefaultSentryCore 创建 Error 级别以上上报 sentry 的默认 sentry core ， tags 会添加到默认的 tags 中
+func newDefaultSentryCore(sentryClient *sentry.Client, tags map[string]string) zapcore.Core {
 	cfg := SentryCoreConfig{
 		Level: zap.ErrorLevel,
 		Tags: map[string]string{
@@ -175,6 +180,8 @@ func SentryAttach(l *zap.Logger, sentryClient *sentry.Client) *zap.Logger {
 			"server_ip": ServerIP(),
 		},
 	}
-	core := NewSentryCore(cfg, sentryClient)
-	return AttachCore(l, core)
+	for k, v := range tags {
+		cfg.Tags[k] = v
+	}
+	return NewSentryCore(cfg, sentryClient)
 }
@@ -0,0 +1,203 @@
+// log/slog 的 Handler 实现，使用 zap logger 实际打印日志
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SlogHandler 实现 slog.Handler 接口，通过 zap logger 打印日志
+// 在 slog 的 *Context 方法中传入的 context 中如果带有 ctx logger 或 trace id ，打印时会自动带上
+type SlogHandler struct {
+	// 为 nil 时使用 context 中的 ctx logger 或默认 logger
+	logger *zap.Logger
+	// WithGroup 和 WithAttrs 累积的字段， group 使用 zap.Namespace 表示
+	fields []zap.Field
+	// 末尾尚未添加任何字段的 group ，打印时没有字段的 group 不输出
+	emptyGroups []string
+}
+
+// NewSlogHandler 创建 SlogHandler
+// l 为 nil 时使用 context 中的 ctx logger ，不存在则使用默认 logger 克隆出的 ctx logger
+// slog.SetDefault(slog.New(logging.NewSlogHandler(nil)))
+func NewSlogHandler(l *zap.Logger) *SlogHandler {
+	return &SlogHandler{logger: l}
+}
+
+// SlogLevelToZap 将 slog 的日志级别转换为 zap 的日志级别
+// 介于两个 slog 级别之间的自定义级别向下取整
+func SlogLevelToZap(lvl slog.Level) zapcore.Level {
+	switch {
+	case lvl >= slog.LevelError:
+		return zap.ErrorLevel
+	case lvl >= slog.LevelWarn:
+		return zap.WarnLevel
+	case lvl >= slog.LevelInfo:
+		return zap.InfoLevel
+	default:
+		return zap.DebugLevel
+	}
+}
+
+// ctxLogger 获取本次打印日志使用的 logger
+func (h *SlogHandler) ctxLogger(ctx context.Context) *zap.Logger {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if h.logger == nil {
+		return CtxLogger(ctx)
+	}
+	// 使用创建时指定的 logger ，只从 context 中获取 trace id 、 span id 等字段
+	if traceID := lookupTraceID(ctx); traceID != "" {
+		fields, _, _, _ := ctxLoggerFields(ctx, traceID)
+		return h.logger.With(fields...)
+	}
+	return h.logger
+}
+
+// Enabled 实现 slog.Handler 接口
+// 日志级别未开启但 context 开启了 debug 日志时返回 true ，由 Handle 使用开启了 debug 的 ctx logger 判断
+func (h *SlogHandler) Enabled(ctx context.Context, lvl slog.Level) bool {
+	l := h.logger
+	if l == nil {
+		rwMutex.RLock()
+		l = logger
+		rwMutex.RUnlock()
+	}
+	if l == nil {
+		return false
+	}
+	if l.Core().Enabled(SlogLevelToZap(lvl)) {
+		return true
+	}
+	if ctx == nil {
+		return false
+	}
+	return CtxForceDebug(ctx) || IsForceDebugTraceID(lookupTraceID(ctx))
+}
+
+// Handle 实现 slog.Handler 接口
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	l := h.ctxLogger(ctx)
+	ce := l.Check(SlogLevelToZap(record.Level), record.Message)
+	if ce == nil {
+		return nil
+	}
+	if !record.Time.IsZero() {
+		ce.Time = record.Time
+	}
+	// 使用 slog 记录的调用位置替换 zap 计算的 caller
+	if ce.Caller.Defined && record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		ce.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+	}
+
+	fields := make([]zap.Field, 0, len(h.fields)+record.NumAttrs())
+	fields = append(fields, h.fields...)
+	record.Attrs(func(attr slog.Attr) bool {
+		if field, ok := slogAttrToField(attr); ok {
+			fields = append(fields, field)
+		}
+		return true
+	})
+	// 没有任何字段的 group 不输出
+	if len(fields) == len(h.fields) && len(h.emptyGroups) > 0 {
+		fields = fields[:len(fields)-len(h.emptyGroups)]
+	}
+	ce.Write(fields...)
+	return nil
+}
+
+// WithAttrs 实现 slog.Handler 接口
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]zap.Field, 0, len(attrs))
+	for _, attr := range attrs {
+		if field, ok := slogAttrToField(attr); ok {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return h
+	}
+	nh := h.clone()
+	nh.fields = append(nh.fields, fields...)
+	nh.emptyGroups = nil
+	return nh
+}
+
+// WithGroup 实现 slog.Handler 接口
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	nh := h.clone()
+	nh.fields = append(nh.fields, zap.Namespace(name))
+	nh.emptyGroups = append(nh.emptyGroups, name)
+	return nh
+}
+
+func (h *SlogHandler) clone() *SlogHandler {
+	return &SlogHandler{
+		logger:      h.logger,
+		fields:      append([]zap.Field{}, h.fields...),
+		emptyGroups: append([]string{}, h.emptyGroups...),
+	}
+}
+
+// slogAttrToField 将 slog.Attr 转换为 zap.Field ，空 attr 返回 false
+func slogAttrToField(attr slog.Attr) (zap.Field, bool) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return zap.Skip(), false
+	}
+	value := attr.Value
+	switch value.Kind() {
+	case slog.KindString:
+		return zap.String(attr.Key, value.String()), true
+	case slog.KindInt64:
+		return zap.Int64(attr.Key, value.Int64()), true
+	case slog.KindUint64:
+		return zap.Uint64(attr.Key, value.Uint64()), true
+	case slog.KindFloat64:
+		return zap.Float64(attr.Key, value.Float64()), true
+	case slog.KindBool:
+		return zap.Bool(attr.Key, value.Bool()), true
+	case slog.KindDuration:
+		return zap.Duration(attr.Key, value.Duration()), true
+	case slog.KindTime:
+		return zap.Time(attr.Key, value.Time()), true
+	case slog.KindGroup:
+		attrs := value.Group()
+		if len(attrs) == 0 {
+			return zap.Skip(), false
+		}
+		// key 为空的 group 按 slog 约定展开到上一层，这里使用 zap.Inline 实现
+		if attr.Key == "" {
+			return zap.Inline(slogGroup(attrs)), true
+		}
+		return zap.Object(attr.Key, slogGroup(attrs)), true
+	default:
+		if err, ok := value.Any().(error); ok {
+			return zap.NamedError(attr.Key, err), true
+		}
+		return zap.Any(attr.Key, value.Any()), true
+	}
+}
+
+// slogGroup 将 slog group 作为 zap object 打印
+type slogGroup []slog.Attr
+
+// MarshalLogObject 实现 zapcore.ObjectMarshaler 接口
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, attr := range g {
+		if field, ok := slogAttrToField(attr); ok {
+			field.AddTo(enc)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSlogHandler(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	slogger := slog.New(NewSlogHandler(zap.New(core)))
+
+	slogger.Debug("debug should not be logged")
+	// context 中的 ctx logger 使用其他 core ，日志应该使用 handler 的 logger 打印
+	ctxCore, ctxLogs := observer.New(zap.DebugLevel)
+	ctx, _ := NewCtxLogger(context.Background(), zap.New(ctxCore), "slog-trace-id")
+	slogger.With("k1", "v1").WithGroup("g").InfoContext(ctx, "info", "k2", 2)
+	slogger.WithGroup("empty").Warn("warn")
+
+	if ctxLogs.Len() != 0 {
+		t.Fatal("ctx logger should not be used when handler has a logger")
+	}
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatal("invalid entries count", len(entries))
+	}
+	m := entries[0].ContextMap()
+	if m[string(TraceIDKeyname)] != "slog-trace-id" {
+		t.Error("trace id not found", m)
+	}
+	if m["k1"] != "v1" {
+		t.Error("invalid k1", m)
+	}
+	if g, ok := m["g"].(map[string]interface{}); !ok || g["k2"] != int64(2) {
+		t.Error("invalid group", m)
+	}
+	if entries[1].Level != zap.WarnLevel {
+		t.Error("invalid level", entries[1].Level)
+	}
+	if _, exists := entries[1].ContextMap()["empty"]; exists {
+		t.Error("empty group should not be logged")
+	}
+}
+
+func TestSlogLevelToZap(t *testing.T) {
+	cases := map[slog.Level]string{
+		slog.LevelDebug:     "debug",
+		slog.LevelInfo:      "info",
+		slog.LevelInfo + 2:  "info",
+		slog.LevelWarn:      "warn",
+		slog.LevelError:     "error",
+		slog.LevelError + 4: "error",
+	}
+	for lvl, expect := range cases {
+		if zlvl := SlogLevelToZap(lvl); zlvl != ZapcoreLevelMap[expect] {
+			t.Error(lvl, "should be", expect, "got", zlvl)
+		}
+	}
+}
+
+func TestSlogHandlerDefaultLogger(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+	slogger := slog.New(NewSlogHandler(nil))
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("slog"), "slog-default")
+	slogger.InfoContext(ctx, "slog with default logger", slog.Group("req", "id", 1))
+
+	entries := logs.AllUntimed()
+	if len(entries) != 1 || entries[0].LoggerName != "slog" {
+		t.Fatal("should use the ctx logger", entries)
+	}
+	m := entries[0].ContextMap()
+	if m[string(TraceIDKeyname)] != "slog-default" {
+		t.Error("trace id not found", m)
+	}
+	if req, ok := m["req"].(map[string]interface{}); !ok || req["id"] != int64(1) {
+		t.Error("invalid group", m)
+	}
+}
+
+func TestSlogHandlerForceDebug(t *testing.T) {
+	obsCore, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(newNamedLevelCore(obsCore, zap.NewAtomicLevelAt(zap.InfoLevel))))()
+	slogger := slog.New(NewSlogHandler(nil))
+	ctx, _ := NewCtxLogger(context.Background(), CloneLogger("slog"), "slog-normal")
+	slogger.DebugContext(ctx, "normal debug")
+	ctx, _ = NewCtxLogger(WithForceDebug(context.Background()), CloneLogger("slog"), "slog-forced")
+	slogger.DebugContext(ctx, "forced debug")
+	if entries := logs.AllUntimed(); len(entries) != 1 || entries[0].Message != "forced debug" {
+		t.Fatal("force debug context should log debug entries", entries)
+	}
+
+	// 零值 handler 在没有默认 logger 时不会 panic
+	defer func(l *zap.Logger) { logger = l }(logger)
+	logger = nil
+	if (&SlogHandler{}).Enabled(context.Background(), slog.LevelInfo) {
+		t.Fatal("handler without logger should not be enabled")
+	}
+}
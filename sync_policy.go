@@ -0,0 +1,96 @@
+// 日志输出的 Sync 策略
+// 按策略定时或在打印指定级别及以上的日志后 Sync 日志输出，代替每次打印日志后 Sync
+// 没有设置策略时只在打印 Panic 及以上级别的日志、调用 Sync 或关闭 logger 时 Sync
+
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SyncPolicy 日志输出的 Sync 策略
+type SyncPolicy struct {
+	Interval time.Duration // 大于 0 时每隔 Interval Sync 一次
+	Level    string        // 打印该级别及以上的日志后立即 Sync ，为空时不按日志级别 Sync
+}
+
+// syncPolicyCore 打印 level 及以上级别的日志后 Sync 的 core
+type syncPolicyCore struct {
+	zapcore.Core
+	// 按策略 Sync 的日志输出，不包含 Sync 时会等待上报完成的 sentry core
+	outputs zapcore.Core
+	level   zapcore.Level
+}
+
+// syncAfterWriteCore 添加到 CheckedEntry 的最后，在其他 core 写入日志后 Sync
+type syncAfterWriteCore struct {
+	zapcore.Core
+}
+
+// Write 调用 Sync 代替写入日志
+func (c syncAfterWriteCore) Write(zapcore.Entry, []zapcore.Field) error {
+	return c.Core.Sync()
+}
+
+// newSyncPolicyCore 根据 policy 创建 core ，按策略只 Sync outputs ，返回停止定时 Sync 的函数
+func newSyncPolicyCore(core, outputs zapcore.Core, policy *SyncPolicy) (zapcore.Core, func(), error) {
+	stop := func() {}
+	if policy == nil {
+		return core, stop, nil
+	}
+	if policy.Interval > 0 {
+		ticker := time.NewTicker(policy.Interval)
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			for {
+				select {
+				case <-ticker.C:
+					outputs.Sync()
+				case <-done:
+					return
+				}
+			}
+		}()
+		// 等待正在进行的 Sync 完成，之后才能关闭日志输出
+		stop = func() {
+			ticker.Stop()
+			close(done)
+			<-stopped
+		}
+	}
+	if policy.Level == "" {
+		return core, stop, nil
+	}
+	level, exists := ZapcoreLevelMap[strings.ToLower(policy.Level)]
+	if !exists {
+		stop()
+		return nil, nil, fmt.Errorf("invalid sync policy level: %s", policy.Level)
+	}
+	return &syncPolicyCore{Core: core, outputs: outputs, level: level}, stop, nil
+}
+
+// With zap core interface ， Sync 不受字段影响，共享 outputs
+func (c *syncPolicyCore) With(fields []zapcore.Field) zapcore.Core {
+	return &syncPolicyCore{Core: c.Core.With(fields), outputs: c.outputs, level: c.level}
+}
+
+// Check zap core interface ，内层 core 会写入日志且级别满足时在最后添加 Sync
+func (c *syncPolicyCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.level {
+		return c.Core.Check(ent, ce)
+	}
+	in := ce
+	ce = c.Core.Check(ent, ce)
+	// 传入的 ce 为 nil 时返回非 nil 说明内层 core 接受了日志，
+	// 作为 tee 中的后续 core 传入非 nil 的 ce 时 AddCore 不会改变指针，只能按日志级别判断
+	if ce == nil || (ce == in && !c.Core.Enabled(ent.Level)) {
+		return ce
+	}
+	return ce.AddCore(ent, syncAfterWriteCore{c.outputs})
+}
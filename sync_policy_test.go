@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSyncPolicyLevel(t *testing.T) {
+	ws := &testWriteSyncer{}
+	output := zapcore.NewCore(zapcore.NewJSONEncoder(EncoderConfig), ws, zap.DebugLevel)
+	core, stop, err := newSyncPolicyCore(output, output, &SyncPolicy{Level: "warn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	logger := zap.New(core).With(zap.String("k", "v"))
+	logger.Info("info")
+	if ws.syncs != 0 {
+		t.Fatal("info should not sync", ws.syncs)
+	}
+	logger.Warn("warn")
+	logger.Error("error")
+	if ws.syncs != 2 || ws.writes != 3 {
+		t.Fatal("warn and error should sync after write", ws.syncs, ws.writes)
+	}
+
+	if _, _, err := newSyncPolicyCore(zapcore.NewNopCore(), zapcore.NewNopCore(), &SyncPolicy{Level: "x"}); err == nil {
+		t.Fatal("invalid level should return error")
+	}
+}
+
+func TestSyncPolicyOutputsOnly(t *testing.T) {
+	ws, other := &testWriteSyncer{}, &testWriteSyncer{}
+	output := zapcore.NewCore(zapcore.NewJSONEncoder(EncoderConfig), ws, zap.ErrorLevel)
+	// other 模拟 sentry 等不需要按策略 Sync 的 core
+	full := zapcore.NewTee(output, zapcore.NewCore(zapcore.NewJSONEncoder(EncoderConfig), other, zap.DebugLevel))
+	core, stop, err := newSyncPolicyCore(full, output, &SyncPolicy{Level: "warn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	zap.New(core).Error("error")
+	if ws.syncs != 1 || other.syncs != 0 {
+		t.Fatal("only outputs should be synced", ws.syncs, other.syncs)
+	}
+
+	// 作为 tee 中的后续 core 时，内层 core 不接受的日志不 Sync
+	policyCore, stop2, err := newSyncPolicyCore(output, output, &SyncPolicy{Level: "warn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop2()
+	zap.New(zapcore.NewTee(zapcore.NewCore(zapcore.NewJSONEncoder(EncoderConfig), other, zap.DebugLevel), policyCore)).Warn("warn")
+	if ws.syncs != 1 || ws.writes != 1 {
+		t.Fatal("declined entry should not sync", ws.syncs, ws.writes)
+	}
+}
+
+func TestSyncPolicyInterval(t *testing.T) {
+	ws := &testWriteSyncer{}
+	output := zapcore.NewCore(zapcore.NewJSONEncoder(EncoderConfig), ws, zap.DebugLevel)
+	_, stop, err := newSyncPolicyCore(output, output, &SyncPolicy{Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(55 * time.Millisecond)
+	stop()
+	ws.mutex.Lock()
+	syncs := ws.syncs
+	ws.mutex.Unlock()
+	if syncs == 0 {
+		t.Fatal("interval policy should sync periodically")
+	}
+	time.Sleep(30 * time.Millisecond)
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	if ws.syncs != syncs {
+		t.Fatal("stop should stop periodic sync")
+	}
+}
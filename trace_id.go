@@ -0,0 +1,307 @@
+// trace id 生成器和校验
+// 内置 xid 、 UUIDv4 、 UUIDv7 、 ULID 、 128 位十六进制（兼容 OpenTelemetry ）和 snowflake 格式的 trace id 生成器
+// 请求中超长或包含控制字符等不安全字符的 trace id ，以及设置校验函数后格式不合法的 trace id 会被丢弃并生成新的 trace id
+
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/xid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDGenerator trace id 生成器
+type TraceIDGenerator interface {
+	NewTraceID() string
+}
+
+// TraceIDGeneratorFunc 使用函数实现 TraceIDGenerator
+type TraceIDGeneratorFunc func() string
+
+// NewTraceID TraceIDGenerator interface
+func (f TraceIDGeneratorFunc) NewTraceID() string {
+	return f()
+}
+
+// TraceIDValidator 校验请求中的 trace id 格式是否合法
+type TraceIDValidator func(traceID string) bool
+
+const (
+	// MaxTraceIDLength 请求中 trace id 的最大长度，超过时生成新的 trace id
+	MaxTraceIDLength = 128
+	// 记录不合法的 trace id 原始值时的最大长度
+	invalidTraceIDMaxLength = 64
+)
+
+var (
+	// XIDTraceIDGenerator 默认的 trace id 生成器，生成 TraceIDPrefix 加 xid 格式的 trace id
+	XIDTraceIDGenerator TraceIDGenerator = TraceIDGeneratorFunc(func() string {
+		return TraceIDPrefix + xid.New().String()
+	})
+	// UUIDv4TraceIDGenerator 生成随机的 UUIDv4 格式的 trace id
+	UUIDv4TraceIDGenerator TraceIDGenerator = TraceIDGeneratorFunc(newUUIDv4)
+	// UUIDv7TraceIDGenerator 生成按时间排序的 UUIDv7 格式的 trace id
+	UUIDv7TraceIDGenerator TraceIDGenerator = TraceIDGeneratorFunc(newUUIDv7)
+	// ULIDTraceIDGenerator 生成按时间排序的 ULID 格式的 trace id
+	ULIDTraceIDGenerator TraceIDGenerator = TraceIDGeneratorFunc(newULID)
+	// Hex128TraceIDGenerator 生成 32 位小写十六进制的 trace id ，与 W3C Trace Context 和 OpenTelemetry 兼容
+	Hex128TraceIDGenerator TraceIDGenerator = TraceIDGeneratorFunc(newHex128)
+
+	// InvalidTraceIDKeyname 请求中的 trace id 不合法被替换时，记录截断后的原始值的字段名
+	InvalidTraceIDKeyname Ctxkey = "invalid_trace_id"
+
+	// 全局的 trace id 生成器和校验函数
+	traceIDGenerator atomic.Value // traceIDGeneratorHolder
+	traceIDValidator atomic.Value // TraceIDValidator
+)
+
+// traceIDGeneratorHolder atomic.Value 只能保存相同类型的值
+type traceIDGeneratorHolder struct {
+	TraceIDGenerator
+}
+
+// SetTraceIDGenerator 设置全局的 trace id 生成器，为 nil 时使用 XIDTraceIDGenerator
+func SetTraceIDGenerator(generator TraceIDGenerator) {
+	if generator == nil {
+		generator = XIDTraceIDGenerator
+	}
+	traceIDGenerator.Store(traceIDGeneratorHolder{generator})
+}
+
+// GetTraceIDGenerator 返回全局的 trace id 生成器
+func GetTraceIDGenerator() TraceIDGenerator {
+	if holder, ok := traceIDGenerator.Load().(traceIDGeneratorHolder); ok {
+		return holder.TraceIDGenerator
+	}
+	return XIDTraceIDGenerator
+}
+
+// SetTraceIDValidator 设置全局的 trace id 校验函数，为 nil 时不校验请求中的 trace id
+func SetTraceIDValidator(validator TraceIDValidator) {
+	traceIDValidator.Store(validator)
+}
+
+// GetTraceIDValidator 返回全局的 trace id 校验函数，没有设置时返回 nil
+func GetTraceIDValidator() TraceIDValidator {
+	validator, _ := traceIDValidator.Load().(TraceIDValidator)
+	return validator
+}
+
+// AnyTraceIDValidator 满足任意一个校验函数时 trace id 合法
+func AnyTraceIDValidator(validators ...TraceIDValidator) TraceIDValidator {
+	return func(traceID string) bool {
+		for _, validate := range validators {
+			if validate(traceID) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// validTraceID trace id 不为空、满足 IsSafeTraceID 且满足 validator ， validator 为 nil 时不校验格式
+func validTraceID(traceID string, validator TraceIDValidator) bool {
+	return traceID != "" && IsSafeTraceID(traceID) && (validator == nil || validator(traceID))
+}
+
+// IsSafeTraceID 判断请求中的 trace id 是否可以安全地写入日志和响应 header ：
+// 长度不超过 MaxTraceIDLength ，只包含字母、数字和 - _ . : ，不包含换行符等控制字符
+func IsSafeTraceID(traceID string) bool {
+	if len(traceID) > MaxTraceIDLength {
+		return false
+	}
+	for i := 0; i < len(traceID); i++ {
+		c := traceID[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+// truncateTraceID 截断不合法的 trace id 用于记录原始值，去掉截断后不完整的 utf8 字符
+func truncateTraceID(traceID string) string {
+	if len(traceID) <= invalidTraceIDMaxLength {
+		return traceID
+	}
+	return strings.ToValidUTF8(traceID[:invalidTraceIDMaxLength], "")
+}
+
+// SnowflakeTraceIDGenerator 生成 snowflake 格式的 trace id ： 41 位毫秒时间戳、 10 位节点 id 和 12 位序列号组成的十进制数字
+type SnowflakeTraceIDGenerator struct {
+	mu       sync.Mutex
+	node     int64
+	lastTime int64
+	sequence int64
+}
+
+const (
+	// snowflake 时间戳的起始时间 2020-01-01 00:00:00 UTC 的毫秒时间戳
+	snowflakeEpoch     = 1577836800000
+	snowflakeNodeBits  = 10
+	snowflakeSeqBits   = 12
+	snowflakeMaxNode   = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSeq    = 1<<snowflakeSeqBits - 1
+	snowflakeTimeShift = snowflakeNodeBits + snowflakeSeqBits
+	snowflakeNodeShift = snowflakeSeqBits
+	// ULID 使用的 Crockford Base32 字符
+	crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// NewSnowflakeTraceIDGenerator 创建 snowflake trace id 生成器， node 取值范围为 0-1023 ，多个实例需要使用不同的 node
+func NewSnowflakeTraceIDGenerator(node int64) (*SnowflakeTraceIDGenerator, error) {
+	if node < 0 || node > snowflakeMaxNode {
+		return nil, errors.New("snowflake node must be between 0 and 1023")
+	}
+	return &SnowflakeTraceIDGenerator{node: node}, nil
+}
+
+// NewTraceID TraceIDGenerator interface ，同一毫秒内序列号用完时等待下一毫秒
+func (g *SnowflakeTraceIDGenerator) NewTraceID() string {
+	g.mu.Lock()
+	now := time.Now().UnixMilli() - snowflakeEpoch
+	// 时钟回拨时继续使用上次的时间
+	if now < g.lastTime {
+		now = g.lastTime
+	}
+	if now == g.lastTime {
+		g.sequence = (g.sequence + 1) & snowflakeMaxSeq
+		if g.sequence == 0 {
+			for now <= g.lastTime {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Now().UnixMilli() - snowflakeEpoch
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastTime = now
+	id := now<<snowflakeTimeShift | g.node<<snowflakeNodeShift | g.sequence
+	g.mu.Unlock()
+	return strconv.FormatInt(id, 10)
+}
+
+// IsSnowflakeID 判断是否为 snowflake 格式的 trace id ：大于 0 的 int64 十进制数字
+func IsSnowflakeID(traceID string) bool {
+	if traceID == "" || len(traceID) > 19 || traceID[0] == '0' {
+		return false
+	}
+	for i := 0; i < len(traceID); i++ {
+		if traceID[i] < '0' || traceID[i] > '9' {
+			return false
+		}
+	}
+	_, err := strconv.ParseInt(traceID, 10, 64)
+	return err == nil
+}
+
+// IsUUID 判断是否为 8-4-4-4-12 格式的十六进制 UUID
+func IsUUID(traceID string) bool {
+	if len(traceID) != 36 {
+		return false
+	}
+	for i := 0; i < len(traceID); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if traceID[i] != '-' {
+				return false
+			}
+		default:
+			if !isHex(traceID[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IsULID 判断是否为 26 位 Crockford Base32 格式的 ULID
+func IsULID(traceID string) bool {
+	if len(traceID) != 26 || traceID[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(traceID); i++ {
+		c := traceID[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if strings.IndexByte(crockfordBase32, c) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// newUUIDv4 生成随机的 UUIDv4
+func newUUIDv4() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+// newUUIDv7 生成前 48 位为毫秒时间戳的 UUIDv7
+func newUUIDv7() string {
+	var u [16]byte
+	rand.Read(u[6:])
+	ms := uint64(time.Now().UnixMilli())
+	u[0], u[1], u[2], u[3], u[4], u[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	u[6] = u[6]&0x0f | 0x70
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+// formatUUID 将 16 字节格式化为 8-4-4-4-12 格式
+func formatUUID(u [16]byte) string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// newULID 生成前 48 位为毫秒时间戳、后 80 位随机的 ULID
+func newULID() string {
+	var u [16]byte
+	rand.Read(u[6:])
+	ms := uint64(time.Now().UnixMilli())
+	u[0], u[1], u[2], u[3], u[4], u[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	// 128 位按 5 位一组从低位开始编码为 26 个字符
+	hi, lo := binary.BigEndian.Uint64(u[:8]), binary.BigEndian.Uint64(u[8:])
+	var buf [26]byte
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = crockfordBase32[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
+
+// newHex128 生成 W3C 合法的 128 位十六进制 trace id
+func newHex128() string {
+	var tid trace.TraceID
+	for !tid.IsValid() {
+		rand.Read(tid[:])
+	}
+	return tid.String()
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,197 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTraceIDGenerators(t *testing.T) {
+	snowflake, err := NewSnowflakeTraceIDGenerator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSnowflakeTraceIDGenerator(1024); err == nil {
+		t.Fatal("invalid snowflake node should return error")
+	}
+	cases := []struct {
+		name      string
+		generator TraceIDGenerator
+		validate  TraceIDValidator
+		version   byte
+	}{
+		{"xid", XIDTraceIDGenerator, func(s string) bool { return strings.HasPrefix(s, TraceIDPrefix) }, 0},
+		{"uuidv4", UUIDv4TraceIDGenerator, IsUUID, '4'},
+		{"uuidv7", UUIDv7TraceIDGenerator, IsUUID, '7'},
+		{"ulid", ULIDTraceIDGenerator, IsULID, 0},
+		{"hex128", Hex128TraceIDGenerator, IsW3CTraceID, 0},
+		{"snowflake", snowflake, IsSnowflakeID, 0},
+	}
+	for _, c := range cases {
+		seen := map[string]bool{}
+		last := ""
+		for i := 0; i < 5000; i++ {
+			traceID := c.generator.NewTraceID()
+			if !c.validate(traceID) || seen[traceID] {
+				t.Fatal(c.name, "invalid or duplicate trace id", traceID)
+			}
+			if c.version != 0 && traceID[14] != c.version {
+				t.Fatal(c.name, "invalid uuid version", traceID)
+			}
+			// 按时间排序的 trace id 应该递增
+			if (c.name == "ulid" || c.name == "snowflake") && len(traceID) == len(last) && traceID[:10] < last[:10] {
+				t.Fatal(c.name, "trace id should be sorted by time", last, traceID)
+			}
+			seen[traceID], last = true, traceID
+		}
+	}
+
+	for _, s := range []string{"", "0123", "-1", "99999999999999999999", "12a"} {
+		if IsSnowflakeID(s) {
+			t.Fatal("invalid snowflake id", s)
+		}
+	}
+	for _, s := range []string{"", "01ARZ3NDEKTSV4RRFFQ69G5FAV0", "81ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAU"} {
+		if IsULID(s) {
+			t.Fatal("invalid ulid", s)
+		}
+	}
+	if !IsULID("01arz3ndektsv4rrffq69g5fav") || IsUUID("0191b0b4-5a8e-7c3e-9f1a-2b3c4d5e6f7") || IsUUID("0191b0b4x5a8e-7c3e-9f1a-2b3c4d5e6f70") {
+		t.Fatal("invalid ulid or uuid validation")
+	}
+}
+
+func TestSetTraceIDGenerator(t *testing.T) {
+	defer SetTraceIDGenerator(nil)
+	SetTraceIDGenerator(UUIDv7TraceIDGenerator)
+	if traceID := CtxTraceID(context.Background()); !IsUUID(traceID) {
+		t.Fatal("CtxTraceID should use the global generator", traceID)
+	}
+	SetTraceIDGenerator(nil)
+	if traceID := CtxTraceID(nil); !strings.HasPrefix(traceID, TraceIDPrefix) {
+		t.Fatal("nil generator should use xid", traceID)
+	}
+}
+
+func TestGinLoggerTraceIDValidator(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	app.Use(GinLoggerWithConfig(GinLoggerConfig{
+		TraceIDGenerator: Hex128TraceIDGenerator,
+		TraceIDValidator: AnyTraceIDValidator(IsW3CTraceID, IsUUID),
+	}))
+	app.GET("/trace", func(c *gin.Context) {})
+	cases := []struct {
+		traceID string
+		keep    bool
+	}{
+		{"", false},
+		{"not a valid trace id", false},
+		{"0191b0b4-5a8e-7c3e-9f1a-2b3c4d5e6f70", true},
+		{testW3CTraceID, true},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/trace", nil)
+		req.Header.Set(string(TraceIDKeyname), c.traceID)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		traceID := w.Header().Get(string(TraceIDKeyname))
+		if c.keep != (traceID == c.traceID) {
+			t.Fatal("invalid response trace id", c.traceID, traceID)
+		}
+		if !c.keep && !IsW3CTraceID(traceID) {
+			t.Fatal("invalid trace id should be replaced by the config generator", traceID)
+		}
+	}
+}
+
+func TestGinLoggerTraceIDFuncNotValidated(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	app.Use(GinLoggerWithConfig(GinLoggerConfig{
+		TraceIDFunc:      func(context.Context) string { return "custom-trace-id" },
+		TraceIDValidator: IsW3CTraceID,
+	}))
+	app.GET("/trace", func(c *gin.Context) {})
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/trace", nil))
+	if traceID := w.Header().Get(string(TraceIDKeyname)); traceID != "custom-trace-id" {
+		t.Fatal("trace id from TraceIDFunc should not be validated", traceID)
+	}
+
+	// 使用从请求中获取 trace id 的 TraceIDFunc 时仍然替换不能安全写入日志的 trace id
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+	app = gin.New()
+	app.Use(GinLoggerWithConfig(GinLoggerConfig{
+		TraceIDFunc: func(c context.Context) string { return GetGinTraceIDFromQueryString(c.(*gin.Context)) },
+	}))
+	app.GET("/trace", func(c *gin.Context) {})
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/trace?trace_id=a%0Ab", nil))
+	if traceID := w.Header().Get(string(TraceIDKeyname)); traceID == "" || !IsSafeTraceID(traceID) {
+		t.Fatal("unsafe trace id from TraceIDFunc should be replaced", traceID)
+	}
+	if entries := logs.AllUntimed(); len(entries) != 1 || entries[0].ContextMap()[string(InvalidTraceIDKeyname)] != "a\nb" {
+		t.Fatal("unsafe trace id should be recorded", entries)
+	}
+}
+
+func TestIsSafeTraceID(t *testing.T) {
+	for _, s := range []string{"", "logging_abc", testW3CTraceID, "0191b0b4-5a8e-7c3e-9f1a-2b3c4d5e6f70", "a.b:c_d", strings.Repeat("a", MaxTraceIDLength)} {
+		if !IsSafeTraceID(s) {
+			t.Fatal("should be safe", s)
+		}
+	}
+	for _, s := range []string{"a\nb", "a\rb", "a\x1b[31mb", "a b", "a\"b", "中文", strings.Repeat("a", MaxTraceIDLength+1)} {
+		if IsSafeTraceID(s) {
+			t.Fatal("should not be safe", s)
+		}
+	}
+	if truncated := truncateTraceID(strings.Repeat("中", 30)); len(truncated) > invalidTraceIDMaxLength || truncated != strings.Repeat("中", 21) {
+		t.Fatal("invalid truncated trace id", truncated)
+	}
+}
+
+func TestGinLoggerSanitizeTraceID(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	app.Use(GinLogger())
+	app.GET("/trace", func(c *gin.Context) {
+		Info(c, "handler")
+	})
+
+	injected := "abc\n{\"level\":\"error\",\"msg\":\"fake\"}" + strings.Repeat("x", 100)
+	req := httptest.NewRequest(http.MethodGet, "/trace", nil)
+	req.Header.Set(string(TraceIDKeyname), injected)
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+
+	traceID := w.Header().Get(string(TraceIDKeyname))
+	if !strings.HasPrefix(traceID, TraceIDPrefix) {
+		t.Fatal("invalid trace id should be replaced", traceID)
+	}
+	entries := logs.FilterMessage("handler").AllUntimed()
+	if len(entries) != 1 {
+		t.Fatal("invalid log count", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields[string(TraceIDKeyname)] != traceID || fields[string(InvalidTraceIDKeyname)] != injected[:invalidTraceIDMaxLength] {
+		t.Fatal("invalid trace id fields", fields)
+	}
+
+	// query string 中的不合法 trace id 同样被替换
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/trace?trace_id=a%0Ab", nil))
+	if traceID := w.Header().Get(string(TraceIDKeyname)); !strings.HasPrefix(traceID, TraceIDPrefix) {
+		t.Fatal("invalid query trace id should be replaced", traceID)
+	}
+}
@@ -0,0 +1,204 @@
+// W3C Trace Context 和 OpenTelemetry span 的支持
+// https://www.w3.org/TR/trace-context/
+
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TraceparentHeader W3C Trace Context 的 traceparent header 名称
+	TraceparentHeader = "traceparent"
+	// TracestateHeader W3C Trace Context 的 tracestate header 名称
+	TracestateHeader = "tracestate"
+)
+
+var (
+	// SpanIDKeyname define the span id keyname
+	SpanIDKeyname Ctxkey = "span_id"
+	// TracestateKeyname define the tracestate keyname
+	TracestateKeyname Ctxkey = "tracestate"
+	// ParentSpanIDKeyname define the parent span id keyname ，记录调用方传入的 span id
+	ParentSpanIDKeyname Ctxkey = "parent_span_id"
+)
+
+// ParseTraceparent 解析 W3C traceparent header ，返回 trace id 、 parent span id 和 trace flags
+// 格式不合法时 ok 返回 false
+func ParseTraceparent(traceparent string) (traceID, spanID string, flags trace.TraceFlags, ok bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 {
+		return "", "", 0, false
+	}
+	version := parts[0]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" {
+		return "", "", 0, false
+	}
+	// version 00 只能有 4 个部分，更高的版本允许在末尾追加字段
+	if version == "00" && len(parts) != 4 {
+		return "", "", 0, false
+	}
+	if !IsW3CTraceID(parts[1]) || !IsW3CSpanID(parts[2]) {
+		return "", "", 0, false
+	}
+	if len(parts[3]) != 2 || !isLowerHex(parts[3]) {
+		return "", "", 0, false
+	}
+	b, _ := hex.DecodeString(parts[3])
+	return parts[1], parts[2], trace.TraceFlags(b[0]), true
+}
+
+// FormatTraceparent 生成 version 为 00 的 W3C traceparent header 值
+// trace id 或 span id 不合法时返回空字符串
+func FormatTraceparent(traceID, spanID string, flags trace.TraceFlags) string {
+	if !IsW3CTraceID(traceID) || !IsW3CSpanID(spanID) {
+		return ""
+	}
+	return "00-" + traceID + "-" + spanID + "-" + flags.String()
+}
+
+// IsW3CTraceID 判断是否为 W3C 合法的 trace id ： 32 位小写十六进制且不全为 0
+func IsW3CTraceID(traceID string) bool {
+	if len(traceID) != 32 || !isLowerHex(traceID) {
+		return false
+	}
+	_, err := trace.TraceIDFromHex(traceID)
+	return err == nil
+}
+
+// IsW3CSpanID 判断是否为 W3C 合法的 span id ： 16 位小写十六进制且不全为 0
+func IsW3CSpanID(spanID string) bool {
+	if len(spanID) != 16 || !isLowerHex(spanID) {
+		return false
+	}
+	_, err := trace.SpanIDFromHex(spanID)
+	return err == nil
+}
+
+// NewSpanID 生成一个随机的 W3C span id
+func NewSpanID() string {
+	var sid trace.SpanID
+	for !sid.IsValid() {
+		rand.Read(sid[:])
+	}
+	return sid.String()
+}
+
+// CtxSpanID get span id from context
+// 依次尝试获取 context 中设置的 span id 、 OpenTelemetry span 的 span id 、 traceparent header 中的 parent id
+// 都不存在时返回空字符串
+func CtxSpanID(c context.Context) string {
+	if c == nil {
+		c = context.Background()
+	}
+	if spanID := ctxStoredSpanID(c); spanID != "" {
+		return spanID
+	}
+	_, spanID, _ := ctxW3CSpan(c)
+	return spanID
+}
+
+// CtxTraceparent 返回 context 中的 trace id 和 span id 组成的 W3C traceparent ，不满足 W3C 格式时返回空字符串
+func CtxTraceparent(c context.Context) string {
+	if c == nil {
+		c = context.Background()
+	}
+	traceID := CtxTraceID(c)
+	_, _, flags := ctxW3CSpan(c)
+	return FormatTraceparent(traceID, CtxSpanID(c), flags)
+}
+
+// CtxTracestate 返回 context 中保存的或请求 header 中的 W3C tracestate
+func CtxTracestate(c context.Context) string {
+	if c == nil {
+		c = context.Background()
+	}
+	if gc, ok := c.(*gin.Context); ok {
+		if tracestate := gc.GetString(string(TracestateKeyname)); tracestate != "" {
+			return tracestate
+		}
+		if gc.Request == nil {
+			return ""
+		}
+		if tracestate := gc.Request.Header.Get(TracestateHeader); tracestate != "" {
+			return tracestate
+		}
+		return trace.SpanContextFromContext(gc.Request.Context()).TraceState().String()
+	}
+	tracestate, _ := c.Value(TracestateKeyname).(string)
+	if tracestate == "" {
+		tracestate = trace.SpanContextFromContext(c).TraceState().String()
+	}
+	return tracestate
+}
+
+// ctxStoredSpanID 获取 context 中已经保存的 span id
+func ctxStoredSpanID(c context.Context) string {
+	if gc, ok := c.(*gin.Context); ok {
+		if spanID := gc.GetString(string(SpanIDKeyname)); spanID != "" {
+			return spanID
+		}
+	}
+	spanID, _ := c.Value(SpanIDKeyname).(string)
+	return spanID
+}
+
+// ctxStoredParentSpanID 获取 context 中已经保存的 parent span id
+func ctxStoredParentSpanID(c context.Context) string {
+	if gc, ok := c.(*gin.Context); ok {
+		if parentSpanID := gc.GetString(string(ParentSpanIDKeyname)); parentSpanID != "" {
+			return parentSpanID
+		}
+	}
+	parentSpanID, _ := c.Value(ParentSpanIDKeyname).(string)
+	return parentSpanID
+}
+
+// newServerSpan 返回本服务处理请求使用的 span id 和调用方的 parent span id
+// local 中有本服务的 OpenTelemetry span 时使用它的 span id ，否则 trace id 符合 W3C 格式时生成新的 span id ，
+// 调用方传入的属于同一个 trace 的 span id 作为 parent span id
+func newServerSpan(local context.Context, traceID, incomingTraceID, incomingSpanID string) (spanID, parentSpanID string) {
+	if sc := trace.SpanContextFromContext(local); sc.IsValid() && !sc.IsRemote() && sc.TraceID().String() == traceID {
+		return sc.SpanID().String(), ""
+	}
+	if !IsW3CTraceID(traceID) {
+		return "", ""
+	}
+	if incomingTraceID == traceID {
+		parentSpanID = incomingSpanID
+	}
+	return NewSpanID(), parentSpanID
+}
+
+// ctxW3CSpan 从 context 中的 OpenTelemetry span 或者 gin 请求的 traceparent header 中获取 trace id 和 span id
+func ctxW3CSpan(c context.Context) (traceID, spanID string, flags trace.TraceFlags) {
+	if gc, ok := c.(*gin.Context); ok {
+		if gc.Request == nil {
+			return
+		}
+		if sc := trace.SpanContextFromContext(gc.Request.Context()); sc.IsValid() {
+			return sc.TraceID().String(), sc.SpanID().String(), sc.TraceFlags()
+		}
+		traceID, spanID, flags, _ = ParseTraceparent(gc.Request.Header.Get(TraceparentHeader))
+		return
+	}
+	if sc := trace.SpanContextFromContext(c); sc.IsValid() {
+		return sc.TraceID().String(), sc.SpanID().String(), sc.TraceFlags()
+	}
+	return
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !(s[i] >= '0' && s[i] <= '9' || s[i] >= 'a' && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+const (
+	testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testW3CTraceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testW3CSpanID   = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	traceID, spanID, flags, ok := ParseTraceparent(testTraceparent)
+	if !ok || traceID != testW3CTraceID || spanID != testW3CSpanID || !flags.IsSampled() {
+		t.Fatal("parse traceparent failed", traceID, spanID, flags, ok)
+	}
+	if tp := FormatTraceparent(traceID, spanID, flags); tp != testTraceparent {
+		t.Error("format traceparent failed", tp)
+	}
+
+	invalids := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+	for _, tp := range invalids {
+		if _, _, _, ok := ParseTraceparent(tp); ok {
+			t.Error("traceparent should be invalid:", tp)
+		}
+	}
+	if _, _, _, ok := ParseTraceparent(testTraceparent[:2] + "01" + testTraceparent[2:] + "-extra"); ok {
+		t.Error("invalid version format should be invalid")
+	}
+	if _, _, _, ok := ParseTraceparent("01" + testTraceparent[2:] + "-extra"); !ok {
+		t.Error("future version may have extra fields")
+	}
+}
+
+func TestCtxTraceIDFromOTelSpan(t *testing.T) {
+	tid, _ := trace.TraceIDFromHex(testW3CTraceID)
+	sid, _ := trace.SpanIDFromHex(testW3CSpanID)
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: sid, TraceFlags: trace.FlagsSampled})
+	c := trace.ContextWithSpanContext(context.Background(), sc)
+
+	if traceID := CtxTraceID(c); traceID != testW3CTraceID {
+		t.Fatal("invalid trace id", traceID)
+	}
+	if spanID := CtxSpanID(c); spanID != testW3CSpanID {
+		t.Fatal("invalid span id", spanID)
+	}
+	if tp := CtxTraceparent(c); tp != testTraceparent {
+		t.Fatal("invalid traceparent", tp)
+	}
+
+	c, _ = NewCtxLogger(c, CloneLogger("test"), "")
+	if spanID := c.Value(SpanIDKeyname); spanID != testW3CSpanID {
+		t.Error("span id should be set in context", spanID)
+	}
+	// 指定其他 trace id 时不记录 span id
+	c, _ = NewCtxLogger(context.Background(), CloneLogger("test"), "other")
+	if spanID := CtxSpanID(c); spanID != "" {
+		t.Error("span id should be empty", spanID)
+	}
+}
+
+func TestGinLoggerTraceparent(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	app := gin.New()
+	app.Use(GinLogger())
+	core, logs := observer.New(zap.DebugLevel)
+	defer ReplaceLogger(zap.New(core))()
+	var serverSpanID string
+	app.GET("/traceparent", func(c *gin.Context) {
+		serverSpanID = CtxSpanID(c)
+		// 本服务使用新的 span id ，调用方的 span id 作为 parent span id
+		if CtxTraceID(c) != testW3CTraceID || !IsW3CSpanID(serverSpanID) || serverSpanID == testW3CSpanID {
+			t.Error("invalid trace id or span id in gin context", CtxTraceID(c), serverSpanID)
+		}
+		if CtxSpanID(c.Request.Context()) != serverSpanID {
+			t.Error("request context should use the server span id", CtxSpanID(c.Request.Context()))
+		}
+		Info(c, "traceparent")
+		c.String(200, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/traceparent", nil)
+	req.Header.Set(TraceparentHeader, testTraceparent)
+	req.Header.Set(TracestateHeader, "vendor=value")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if tp := rec.Header().Get(TraceparentHeader); tp != FormatTraceparent(testW3CTraceID, serverSpanID, 1) {
+		t.Error("invalid response traceparent", tp)
+	}
+	fields := logs.FilterMessage("traceparent").AllUntimed()[0].ContextMap()
+	if fields[string(SpanIDKeyname)] != serverSpanID || fields[string(ParentSpanIDKeyname)] != testW3CSpanID {
+		t.Error("invalid span fields", fields)
+	}
+	if ts := rec.Header().Get(TracestateHeader); ts != "vendor=value" {
+		t.Error("invalid response tracestate", ts)
+	}
+	if tid := rec.Header().Get(string(TraceIDKeyname)); tid != testW3CTraceID {
+		t.Error("invalid response trace id", tid)
+	}
+
+	// trace id 不是 W3C 格式时不返回 traceparent
+	req = httptest.NewRequest(http.MethodGet, "/notraceparent", nil)
+	req.Header.Set(string(TraceIDKeyname), "custom-trace-id")
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if tp := rec.Header().Get(TraceparentHeader); tp != "" {
+		t.Error("traceparent should be empty", tp)
+	}
+}